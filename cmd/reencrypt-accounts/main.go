@@ -0,0 +1,36 @@
+// re-encrypts the credentials in an accounts file with the current primary key
+//
+//	REDDITOR_ACCOUNTS_KEY=<new key> REDDITOR_ACCOUNTS_OLD_KEYS=<old key> go run ./cmd/reencrypt-accounts -file accounts.json
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+
+	sdk "github.com/soumitsalman/go-reddit/collector"
+)
+
+func main() {
+	path := flag.String("file", "", "path of the accounts file. defaults to REDDITOR_ACCOUNTS_FILE")
+	flag.Parse()
+	if *path == "" {
+		*path = os.Getenv("REDDITOR_ACCOUNTS_FILE")
+	}
+	if *path == "" {
+		log.Fatalln("no accounts file specified")
+	}
+
+	credential_cipher, err := sdk.NewCredentialCipherFromEnv()
+	if err != nil {
+		log.Fatalln(err)
+	}
+	store, err := sdk.NewFileAccountStore(*path, credential_cipher)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if err := store.Reencrypt(); err != nil {
+		log.Fatalln(err)
+	}
+	log.Println("Re-encrypted", *path)
+}
@@ -1,7 +1,9 @@
 package sdk
 
 import (
-	datautils "github.com/soumitsalman/data-utils"
+	"context"
+	"fmt"
+	"log"
 )
 
 func (collector *RedditCollector) IsUserAuthenticated(userid string) (bool, string) {
@@ -12,14 +14,75 @@ func (collector *RedditCollector) IsUserAuthenticated(userid string) (bool, stri
 	return false, GetRedditAuthorizationUrl(userid, collector.config.RedditClientConfig)
 }
 
-func (collector *RedditCollector) AddCollectionAccount(user RedditUser) {
-	collector.authenticated_users = append(collector.authenticated_users, user)
+// adds the account to the ones being collected or replaces the existing one with the same UserId
+func (collector *RedditCollector) AddCollectionAccount(user RedditUser) error {
+	return collector.accounts.Put(user)
 }
 
 func (collector *RedditCollector) GetCollectionAccount(userid string) *RedditUser {
-	index := datautils.IndexAny(collector.authenticated_users, func(item *RedditUser) bool { return item.UserId == userid })
-	if index >= 0 {
-		return &collector.authenticated_users[index]
+	if userid == _MASTER_COLLECTOR {
+		if collector.master_user == nil {
+			return nil
+		}
+		collector.master_lock.Lock()
+		defer collector.master_lock.Unlock()
+		master := *collector.master_user
+		return &master
+	}
+	user, err := collector.accounts.Get(userid)
+	if err != nil {
+		log.Println("failed loading account", userid, err)
+		return nil
+	}
+	return user
+}
+
+// stops collecting for the account. the master collector account from the config cannot be removed
+func (collector *RedditCollector) RemoveCollectionAccount(userid string) error {
+	return collector.accounts.Delete(userid)
+}
+
+// revokes the account's reddit grant and removes it from the collector
+// if reddit can't revoke the tokens the account is kept so that unlinking can be retried
+func (collector *RedditCollector) UnlinkCollectionAccount(ctx context.Context, userid string) error {
+	if userid == _MASTER_COLLECTOR {
+		return fmt.Errorf("the master collector account can't be unlinked")
+	}
+	user := collector.GetCollectionAccount(userid)
+	if user == nil {
+		return ErrAccountNotFound
+	}
+	if err := NewAuthenticatedRedditClient(user, collector.config.RedditClientConfig).RevokeContext(ctx); err != nil {
+		log.Println("failed revoking tokens for", userid, err)
+		return fmt.Errorf("unlinking %s: %w", userid, err)
+	}
+	return collector.RemoveCollectionAccount(userid)
+}
+
+// all accounts to collect for, starting with the master collector
+func (collector *RedditCollector) collectionAccounts() ([]RedditUser, error) {
+	users, err := collector.accounts.List()
+	if collector.master_user != nil {
+		collector.master_lock.Lock()
+		users = append([]RedditUser{*collector.master_user}, users...)
+		collector.master_lock.Unlock()
+	}
+	return users, err
+}
+
+// copies the tokens obtained by a client into the stored account
+func (collector *RedditCollector) updateCollectionAccountTokens(refreshed RedditUser) {
+	if refreshed.UserId == _MASTER_COLLECTOR {
+		if collector.master_user != nil {
+			collector.master_lock.Lock()
+			defer collector.master_lock.Unlock()
+			collector.master_user.AccessToken = refreshed.AccessToken
+			collector.master_user.RefreshToken = refreshed.RefreshToken
+			collector.master_user.TokenExpiry = refreshed.TokenExpiry
+		}
+		return
+	}
+	if err := collector.accounts.UpdateTokens(refreshed.UserId, refreshed.AccessToken, refreshed.RefreshToken, refreshed.TokenExpiry); err != nil {
+		log.Println("failed storing refreshed tokens for", refreshed.UserId, err)
 	}
-	return nil
 }
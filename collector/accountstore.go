@@ -0,0 +1,210 @@
+package sdk
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// persistence for the reddit accounts linked to the collector
+// accounts are keyed by RedditUser.UserId
+type AccountStore interface {
+	// returns nil and no error if there is no account with the user id
+	Get(user_id string) (*RedditUser, error)
+	// returns all accounts in the order they were first added
+	List() ([]RedditUser, error)
+	// adds the account or replaces the existing one with the same user id
+	Put(user RedditUser) error
+	// removing an account that does not exist is not an error
+	Delete(user_id string) error
+	// updates only the token fields of an existing account
+	UpdateTokens(user_id, access_token, refresh_token string, token_expiry int64) error
+}
+
+var ErrAccountNotFound = errors.New("account not found")
+
+// keeps the accounts in memory only. everything is lost when the process exits
+type MemoryAccountStore struct {
+	lock  sync.RWMutex
+	users []RedditUser
+}
+
+func NewMemoryAccountStore() *MemoryAccountStore {
+	return &MemoryAccountStore{users: make([]RedditUser, 0, 10)}
+}
+
+func (store *MemoryAccountStore) Get(user_id string) (*RedditUser, error) {
+	store.lock.RLock()
+	defer store.lock.RUnlock()
+	if index := store.indexOf(user_id); index >= 0 {
+		user := store.users[index]
+		return &user, nil
+	}
+	return nil, nil
+}
+
+func (store *MemoryAccountStore) List() ([]RedditUser, error) {
+	store.lock.RLock()
+	defer store.lock.RUnlock()
+	return append([]RedditUser(nil), store.users...), nil
+}
+
+func (store *MemoryAccountStore) Put(user RedditUser) error {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	store.put(user)
+	return nil
+}
+
+func (store *MemoryAccountStore) Delete(user_id string) error {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	store.delete(user_id)
+	return nil
+}
+
+func (store *MemoryAccountStore) UpdateTokens(user_id, access_token, refresh_token string, token_expiry int64) error {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	return store.updateTokens(user_id, access_token, refresh_token, token_expiry)
+}
+
+// the unexported versions expect the caller to hold the lock
+
+func (store *MemoryAccountStore) indexOf(user_id string) int {
+	for i := range store.users {
+		if store.users[i].UserId == user_id {
+			return i
+		}
+	}
+	return -1
+}
+
+func (store *MemoryAccountStore) put(user RedditUser) {
+	if index := store.indexOf(user.UserId); index >= 0 {
+		store.users[index] = user
+	} else {
+		store.users = append(store.users, user)
+	}
+}
+
+func (store *MemoryAccountStore) delete(user_id string) {
+	if index := store.indexOf(user_id); index >= 0 {
+		store.users = append(store.users[:index], store.users[index+1:]...)
+	}
+}
+
+func (store *MemoryAccountStore) updateTokens(user_id, access_token, refresh_token string, token_expiry int64) error {
+	index := store.indexOf(user_id)
+	if index < 0 {
+		return ErrAccountNotFound
+	}
+	store.users[index].AccessToken = access_token
+	store.users[index].RefreshToken = refresh_token
+	store.users[index].TokenExpiry = token_expiry
+	return nil
+}
+
+// keeps the accounts in memory and writes all of them to a json file on every change
+// passwords and tokens are encrypted in the file and kept in cleartext only in memory
+type FileAccountStore struct {
+	MemoryAccountStore
+	path              string
+	credential_cipher *CredentialCipher
+}
+
+// loads the accounts from the file at path if it exists. the file gets created on the first change
+func NewFileAccountStore(path string, credential_cipher *CredentialCipher) (*FileAccountStore, error) {
+	if credential_cipher == nil {
+		return nil, errors.New("FileAccountStore needs a CredentialCipher to keep the credentials encrypted")
+	}
+	store := &FileAccountStore{
+		MemoryAccountStore: MemoryAccountStore{users: make([]RedditUser, 0, 10)},
+		path:               path,
+		credential_cipher:  credential_cipher,
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return store, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &store.users); err != nil {
+		return nil, err
+	}
+	for i := range store.users {
+		if err := credential_cipher.transformCredentials(&store.users[i], credential_cipher.Decrypt); err != nil {
+			return nil, err
+		}
+	}
+	return store, nil
+}
+
+// rewrites the file with every credential encrypted by the primary key
+// run this after rotating keys (with the old key still listed), after turning on encryption for an existing file
+// or to bind the values written before they were tied to their account and field
+func (store *FileAccountStore) Reencrypt() error {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	return store.save()
+}
+
+func (store *FileAccountStore) Put(user RedditUser) error {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	store.put(user)
+	return store.save()
+}
+
+func (store *FileAccountStore) Delete(user_id string) error {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	store.delete(user_id)
+	return store.save()
+}
+
+func (store *FileAccountStore) UpdateTokens(user_id, access_token, refresh_token string, token_expiry int64) error {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	if err := store.updateTokens(user_id, access_token, refresh_token, token_expiry); err != nil {
+		return err
+	}
+	return store.save()
+}
+
+// encrypts the credentials and writes all the accounts to the file
+func (store *FileAccountStore) save() error {
+	encrypted := append([]RedditUser(nil), store.users...)
+	for i := range encrypted {
+		if err := store.credential_cipher.transformCredentials(&encrypted[i], store.credential_cipher.Encrypt); err != nil {
+			return err
+		}
+	}
+	data, err := json.MarshalIndent(encrypted, "", "\t")
+	if err != nil {
+		return err
+	}
+	// the file has credentials in it. keep it private to the owner
+	return writeFileAtomically(store.path, data, 0600)
+}
+
+// writes to a temp file first and then renames it so that a crash midway doesn't leave a corrupted file behind
+func writeFileAtomically(path string, data []byte, perm os.FileMode) error {
+	temp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temp.Name())
+	if err = temp.Chmod(perm); err == nil {
+		_, err = temp.Write(data)
+	}
+	if close_err := temp.Close(); err == nil {
+		err = close_err
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(temp.Name(), path)
+}
@@ -0,0 +1,173 @@
+package sdk
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+
+	ds "github.com/soumitsalman/beansack/sdk"
+)
+
+// where collected beans go. a nil error means the beans are durably stored
+type BeanSink interface {
+	Store(ctx context.Context, beans []ds.Bean) error
+}
+
+// lets an ordinary function be a BeanSink
+type BeanSinkFunc func(ctx context.Context, beans []ds.Bean) error
+
+func (store BeanSinkFunc) Store(ctx context.Context, beans []ds.Bean) error {
+	return store(ctx, beans)
+}
+
+// adapts a store function that can't report failures. every call counts as stored
+func StoreFunc(store_func func(beans []ds.Bean)) BeanSink {
+	return BeanSinkFunc(func(_ context.Context, beans []ds.Bean) error {
+		store_func(beans)
+		return nil
+	})
+}
+
+var ErrDeadLettered = errors.New("beans could not be stored and were written to the dead letter directory")
+
+// how the collector hands beans to its BeanSink
+type SinkOptions struct {
+	// max number of beans per Store call. 0 means all the beans of an account go in one call
+	BatchSize int
+	// number of retries of a failed batch. defaults to MAX_REQUEST_RETRIES
+	MaxRetries int
+	// the wait between retries doubles from RetryWaitTime up to RetryMaxWaitTime with some jitter
+	// they default to RETRY_WAIT_TIME and RETRY_MAX_WAIT_TIME
+	RetryWaitTime    time.Duration
+	RetryMaxWaitTime time.Duration
+	// batches that still fail after the retries get written here as json files. empty means they are dropped
+	// RedditCollector.ReplayDeadLetters and Run send them to the sink again
+	DeadLetterDir string
+}
+
+// batches the beans for the underlying sink, retries failed batches with backoff and spills the ones that keep failing to disk
+type RetryingSink struct {
+	sink    BeanSink
+	options SinkOptions
+	counter atomic.Int64 // keeps dead letter file names unique within the same nanosecond
+}
+
+func NewRetryingSink(sink BeanSink, options SinkOptions) *RetryingSink {
+	if options.MaxRetries <= 0 {
+		options.MaxRetries = MAX_REQUEST_RETRIES
+	}
+	if options.RetryWaitTime <= 0 {
+		options.RetryWaitTime = RETRY_WAIT_TIME
+	}
+	if options.RetryMaxWaitTime <= 0 {
+		options.RetryMaxWaitTime = RETRY_MAX_WAIT_TIME
+	}
+	return &RetryingSink{sink: sink, options: options}
+}
+
+// stores all the batches even if some of them fail. the returned error joins the failures
+// batches that made it to the dead letter directory fail with ErrDeadLettered
+func (retrying *RetryingSink) Store(ctx context.Context, beans []ds.Bean) error {
+	batch_size := retrying.options.BatchSize
+	if batch_size <= 0 {
+		batch_size = len(beans)
+	}
+	var errs []error
+	for start := 0; start < len(beans); start += batch_size {
+		batch := beans[start:min(start+batch_size, len(beans))]
+		err := retrying.storeBatch(ctx, batch)
+		if err == nil {
+			continue
+		}
+		if retrying.options.DeadLetterDir == "" {
+			log.Printf("Dropping %d beans: %v\n", len(batch), err)
+			errs = append(errs, err)
+		} else if spill_err := retrying.spill(batch); spill_err != nil {
+			log.Printf("Dropping %d beans. Dead letter failed too: %v\n", len(batch), spill_err)
+			errs = append(errs, err, spill_err)
+		} else {
+			log.Printf("Wrote %d beans to %s: %v\n", len(batch), retrying.options.DeadLetterDir, err)
+			errs = append(errs, fmt.Errorf("%w: %w", ErrDeadLettered, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (retrying *RetryingSink) storeBatch(ctx context.Context, batch []ds.Bean) error {
+	wait := retrying.options.RetryWaitTime
+	for attempt := 0; ; attempt++ {
+		err := retrying.sink.Store(ctx, batch)
+		if err == nil || attempt >= retrying.options.MaxRetries {
+			return err
+		}
+		// full jitter so that concurrent writers don't retry in lockstep
+		select {
+		case <-ctx.Done():
+			return errors.Join(err, ctx.Err())
+		case <-time.After(time.Duration(rand.Int63n(int64(wait)) + 1)):
+		}
+		wait = min(wait*2, retrying.options.RetryMaxWaitTime)
+	}
+}
+
+func (retrying *RetryingSink) spill(batch []ds.Bean) error {
+	if err := os.MkdirAll(retrying.options.DeadLetterDir, 0755); err != nil {
+		return err
+	}
+	data, err := json.Marshal(batch)
+	if err != nil {
+		return err
+	}
+	name := fmt.Sprintf("beans-%d-%d.json", time.Now().UnixNano(), retrying.counter.Add(1))
+	return writeFileAtomically(filepath.Join(retrying.options.DeadLetterDir, name), data, 0644)
+}
+
+// sends the dead letters to the sink again and removes the files that made it
+// files that fail again stay where they are for the next replay
+func (retrying *RetryingSink) ReplayDeadLetters(ctx context.Context) error {
+	if retrying.options.DeadLetterDir == "" {
+		return nil
+	}
+	paths, err := filepath.Glob(filepath.Join(retrying.options.DeadLetterDir, "beans-*.json"))
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, path := range paths {
+		if ctx.Err() != nil {
+			return errors.Join(append(errs, ctx.Err())...)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		var batch []ds.Bean
+		if err := json.Unmarshal(data, &batch); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", filepath.Base(path), err))
+			continue
+		}
+		if err := retrying.storeBatch(ctx, batch); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", filepath.Base(path), err))
+			continue
+		}
+		errs = append(errs, os.Remove(path))
+	}
+	return errors.Join(errs...)
+}
+
+// sends the beans that earlier runs wrote to SinkOptions.DeadLetterDir to the BeanSink again
+// Run does this on its own before the first collection
+func (collector *RedditCollector) ReplayDeadLetters(ctx context.Context) error {
+	if collector.config.BeanSink == nil {
+		return errors.New("CollectorConfig.BeanSink is required")
+	}
+	return collector.sink.ReplayDeadLetters(ctx)
+}
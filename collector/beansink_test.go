@@ -0,0 +1,41 @@
+package sdk
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+
+	ds "github.com/soumitsalman/beansack/sdk"
+)
+
+// beans spilled by a run get stored through the collector once the sink is back
+func TestCollectorReplaysDeadLetters(t *testing.T) {
+	dir := t.TempDir()
+	failing := true
+	var stored []ds.Bean
+	collector := NewCollector(CollectorConfig{
+		BeanSink: BeanSinkFunc(func(_ context.Context, beans []ds.Bean) error {
+			if failing {
+				return errors.New("sink is down")
+			}
+			stored = append(stored, beans...)
+			return nil
+		}),
+		SinkOptions: SinkOptions{MaxRetries: 1, RetryWaitTime: 1, RetryMaxWaitTime: 1, DeadLetterDir: dir},
+	})
+	if err := collector.sink.Store(context.Background(), []ds.Bean{{Url: "https://example.com/a"}}); !errors.Is(err, ErrDeadLettered) {
+		t.Fatalf("expected ErrDeadLettered, got %v", err)
+	}
+
+	failing = false
+	if err := collector.ReplayDeadLetters(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(stored) != 1 || stored[0].Url != "https://example.com/a" {
+		t.Errorf("expected the dead lettered bean to be stored, got %+v", stored)
+	}
+	if files, _ := os.ReadDir(dir); len(files) != 0 {
+		t.Errorf("expected the dead letter to be removed, %d files left", len(files))
+	}
+}
@@ -0,0 +1,167 @@
+package sdk
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"slices"
+	"sync"
+	"time"
+)
+
+const (
+	// posts younger than this get their metrics refreshed on every run even if they were collected before
+	DEFAULT_FRESHNESS_WINDOW = 24 * time.Hour
+	// number of fullnames a checkpoint remembers. the oldest are forgotten first
+	MAX_CHECKPOINT_ITEMS = 1000
+)
+
+// where the previous runs left off
+type Checkpoint struct {
+	LastSeen    string `json:"last_seen,omitempty"`    // fullname of the newest item seen
+	LastCreated int64  `json:"last_created,omitempty"` // creation time of the newest item seen
+	Updated     int64  `json:"updated,omitempty"`      // when the checkpoint was written
+	// fullnames of the items collected so far, oldest first. hot and top listings aren't in time order
+	// so an old post can show up for the first time after newer ones were collected. only these count as collected
+	Collected []string `json:"collected,omitempty"`
+}
+
+// persistence for the checkpoints of subreddits and of the user profiles in TARGET_USER targets
+// keys are "r/<subreddit display name>" for subreddits, "u/<username>" for profiles and "schedule/<name>" for the last runs of Run
+type CheckpointStore interface {
+	// returns nil and no error if there is no checkpoint for the key
+	Get(key string) (*Checkpoint, error)
+	// adds the checkpoint or replaces the existing one
+	Put(key string, checkpoint Checkpoint) error
+}
+
+// keeps the checkpoints in memory only. everything is lost when the process exits
+type MemoryCheckpointStore struct {
+	lock        sync.RWMutex
+	checkpoints map[string]Checkpoint
+}
+
+func NewMemoryCheckpointStore() *MemoryCheckpointStore {
+	return &MemoryCheckpointStore{checkpoints: make(map[string]Checkpoint)}
+}
+
+func (store *MemoryCheckpointStore) Get(key string) (*Checkpoint, error) {
+	store.lock.RLock()
+	defer store.lock.RUnlock()
+	if checkpoint, ok := store.checkpoints[key]; ok {
+		return &checkpoint, nil
+	}
+	return nil, nil
+}
+
+func (store *MemoryCheckpointStore) Put(key string, checkpoint Checkpoint) error {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	store.checkpoints[key] = checkpoint
+	return nil
+}
+
+// keeps the checkpoints in memory and writes all of them to a json file on every change
+type FileCheckpointStore struct {
+	MemoryCheckpointStore
+	path string
+}
+
+// loads the checkpoints from the file at path if it exists. the file gets created on the first change
+func NewFileCheckpointStore(path string) (*FileCheckpointStore, error) {
+	store := &FileCheckpointStore{
+		MemoryCheckpointStore: MemoryCheckpointStore{checkpoints: make(map[string]Checkpoint)},
+		path:                  path,
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return store, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &store.checkpoints); err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+func (store *FileCheckpointStore) Put(key string, checkpoint Checkpoint) error {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	store.checkpoints[key] = checkpoint
+	return store.save()
+}
+
+func (store *FileCheckpointStore) save() error {
+	data, err := json.MarshalIndent(store.checkpoints, "", "\t")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomically(store.path, data, 0644)
+}
+
+func subredditCheckpointKey(subreddit string) string {
+	return "r/" + subreddit
+}
+
+func userCheckpointKey(username string) string {
+	return "u/" + username
+}
+
+// the checkpoint for key or nil if there is no store or no checkpoint yet
+// a failing store doesn't stop the collection. it just means everything gets collected again
+func (collector *RedditCollector) checkpoint(key string) *Checkpoint {
+	if collector.config.CheckpointStore == nil {
+		return nil
+	}
+	checkpoint, err := collector.config.CheckpointStore.Get(key)
+	if err != nil {
+		log.Println("failed loading checkpoint", key, err)
+		return nil
+	}
+	return checkpoint
+}
+
+// moves the checkpoint for key forward to the newest of the items and adds them to the collected ones. it never moves back
+func (collector *RedditCollector) advanceCheckpoint(key string, items []RedditItem) {
+	if collector.config.CheckpointStore == nil {
+		return
+	}
+	collector.checkpoint_lock.Lock()
+	defer collector.checkpoint_lock.Unlock()
+	checkpoint := Checkpoint{}
+	if previous := collector.checkpoint(key); previous != nil {
+		checkpoint = *previous
+	}
+	// the stored slice may be shared with the store. don't append to it in place
+	checkpoint.Collected = slices.Clone(checkpoint.Collected)
+	for _, item := range items {
+		if created := int64(item.CreatedDate); created > checkpoint.LastCreated {
+			checkpoint.LastCreated = created
+			checkpoint.LastSeen = item.Name
+		}
+		if !slices.Contains(checkpoint.Collected, item.Name) {
+			checkpoint.Collected = append(checkpoint.Collected, item.Name)
+		}
+	}
+	if extra := len(checkpoint.Collected) - MAX_CHECKPOINT_ITEMS; extra > 0 {
+		checkpoint.Collected = checkpoint.Collected[extra:]
+	}
+	checkpoint.Updated = time.Now().Unix()
+	if err := collector.config.CheckpointStore.Put(key, checkpoint); err != nil {
+		log.Println("failed storing checkpoint", key, err)
+	}
+}
+
+// true if the item was already collected by an earlier run and is too old to have its metrics refreshed
+func (collector *RedditCollector) isCollected(item *RedditItem, checkpoint *Checkpoint) bool {
+	if checkpoint == nil {
+		return false
+	}
+	window := collector.config.FreshnessWindow
+	if window <= 0 {
+		window = DEFAULT_FRESHNESS_WINDOW
+	}
+	return int64(item.CreatedDate) < time.Now().Add(-window).Unix() && slices.Contains(checkpoint.Collected, item.Name)
+}
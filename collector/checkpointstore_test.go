@@ -0,0 +1,42 @@
+package sdk
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// hot listings aren't in time order. an old post that shows up after a newer one was collected still has to be collected
+func TestIsCollectedByFullname(t *testing.T) {
+	collector := NewCollector(CollectorConfig{CheckpointStore: NewMemoryCheckpointStore(), FreshnessWindow: time.Hour})
+	old := float64(time.Now().Add(-48 * time.Hour).Unix())
+	newer := RedditItem{Name: "t3_newer", CreatedDate: old + 3600}
+	older := RedditItem{Name: "t3_older", CreatedDate: old}
+	fresh := RedditItem{Name: "t3_fresh", CreatedDate: float64(time.Now().Unix())}
+
+	collector.advanceCheckpoint("r/golang", []RedditItem{newer, fresh})
+	checkpoint := collector.checkpoint("r/golang")
+	if !collector.isCollected(&newer, checkpoint) {
+		t.Error("expected the collected post to be skipped")
+	}
+	if collector.isCollected(&older, checkpoint) {
+		t.Error("an older post that was never collected must not be skipped")
+	}
+	if collector.isCollected(&fresh, checkpoint) {
+		t.Error("a post within the freshness window must be refreshed")
+	}
+}
+
+func TestCheckpointCollectedIsBounded(t *testing.T) {
+	collector := NewCollector(CollectorConfig{CheckpointStore: NewMemoryCheckpointStore()})
+	items := make([]RedditItem, MAX_CHECKPOINT_ITEMS+10)
+	for i := range items {
+		items[i] = RedditItem{Name: fmt.Sprintf("t3_%d", i), CreatedDate: float64(i)}
+	}
+	collector.advanceCheckpoint("r/golang", items[:10])
+	collector.advanceCheckpoint("r/golang", items[10:])
+	collected := collector.checkpoint("r/golang").Collected
+	if len(collected) != MAX_CHECKPOINT_ITEMS || collected[0] != "t3_10" || collected[len(collected)-1] != items[len(items)-1].Name {
+		t.Errorf("expected the newest %d fullnames, got %d from %s", MAX_CHECKPOINT_ITEMS, len(collected), collected[0])
+	}
+}
@@ -0,0 +1,124 @@
+package sdk
+
+import (
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// items collected during a single run, shared across all the accounts
+// an item is keyed by its fullname and by its canonical content url so that the same article posted in different subreddits is loaded once
+// the first account to claim an item collects it. the others wait for it and reuse the result
+type collectionCache struct {
+	lock    sync.Mutex
+	by_name map[string]*cachedItem
+	by_url  map[string]*cachedItem
+	items   []*cachedItem // in the order they were claimed
+	// checkpoints as they were when the run started. checkpoints advanced by one account must not hide items from the next
+	checkpoints map[string]*Checkpoint
+}
+
+type cachedItem struct {
+	name     string
+	url      string
+	done     chan struct{} // closed once the owner has finished collecting
+	bean_url string        // url of the stored bean. empty if the item did not have enough text to store
+	children []RedditItem
+	err      error
+	users    []string // ids of the accounts the item was collected for
+}
+
+func newCollectionCache() *collectionCache {
+	return &collectionCache{
+		by_name:     make(map[string]*cachedItem),
+		by_url:      make(map[string]*cachedItem),
+		checkpoints: make(map[string]*Checkpoint),
+	}
+}
+
+// returns the entry for the item and whether the caller owns it. the owner has to call finish once it is done collecting
+// either way the user gets recorded for the item
+func (cache *collectionCache) claim(item *RedditItem, user_id string) (*cachedItem, bool) {
+	content_url := canonicalUrl(item.contentUrl())
+
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+	entry, ok := cache.by_name[item.Name]
+	if !ok && content_url != "" {
+		entry, ok = cache.by_url[content_url]
+	}
+	if !ok {
+		entry = &cachedItem{name: item.Name, url: content_url, done: make(chan struct{})}
+		cache.by_name[item.Name] = entry
+		if content_url != "" {
+			cache.by_url[content_url] = entry
+		}
+		cache.items = append(cache.items, entry)
+	}
+	if !slices.Contains(entry.users, user_id) {
+		entry.users = append(entry.users, user_id)
+	}
+	return entry, !ok
+}
+
+// a failed item is dropped from the cache so that the next account gets to try it again. the failure may be specific to the account
+// only the url of the bean is kept. the bean itself has gone to the sink or the stream and would otherwise stay around until the run is over
+func (cache *collectionCache) finish(entry *cachedItem, bean_url string, children []RedditItem, err error) {
+	cache.lock.Lock()
+	entry.bean_url, entry.children, entry.err = bean_url, children, err
+	if err != nil {
+		delete(cache.by_name, entry.name)
+		delete(cache.by_url, entry.url)
+	}
+	cache.lock.Unlock()
+	close(entry.done)
+}
+
+// the checkpoint for key as it was when it was first asked for in this run
+func (cache *collectionCache) checkpoint(key string, load func(key string) *Checkpoint) *Checkpoint {
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+	checkpoint, ok := cache.checkpoints[key]
+	if !ok {
+		checkpoint = load(key)
+		cache.checkpoints[key] = checkpoint
+	}
+	return checkpoint
+}
+
+// bean url -> ids of the accounts the bean was collected for
+func (cache *collectionCache) collectedFor() map[string][]string {
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+	collected_for := make(map[string][]string)
+	for _, entry := range cache.items {
+		if entry.bean_url != "" && entry.err == nil {
+			collected_for[entry.bean_url] = append([]string(nil), entry.users...)
+		}
+	}
+	return collected_for
+}
+
+// strips what doesn't change the content a url points to: the fragment, tracking parameters, the letter case of the host and the trailing slash
+// the same article shared with different tracking links ends up with the same url
+func canonicalUrl(raw string) string {
+	parsed, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || parsed.Host == "" {
+		return strings.TrimSpace(raw)
+	}
+	parsed.Scheme = strings.ToLower(parsed.Scheme)
+	parsed.Host = strings.TrimPrefix(strings.ToLower(parsed.Host), "www.")
+	parsed.Fragment = ""
+	parsed.Path = strings.TrimSuffix(parsed.Path, "/")
+
+	query := parsed.Query()
+	for param := range query {
+		if strings.HasPrefix(param, "utm_") || param == "ref" || param == "ref_source" {
+			query.Del(param)
+		}
+	}
+	// Encode sorts the parameters
+	parsed.RawQuery = query.Encode()
+	return parsed.String()
+}
@@ -0,0 +1,133 @@
+package sdk
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// what gets collected and how much of it. zero values fall back to the package constants which are the defaults
+type CollectionPolicy struct {
+	// number of posts collected per sort of a subreddit. defaults to MAX_POST_LIMIT
+	PostsPerSubreddit int
+	// sorts to pull the posts of a subreddit with e.g. HOT and TOP of the WEEK. defaults to HOT
+	PostSorts []PostsOptions
+	// overrides PostSorts for specific subreddits. keyed by subreddit display name e.g. "golang"
+	SubredditPostSorts map[string][]PostsOptions
+	// only comments up to this depth go into the digest of a post. 1 means top level comments only. 0 means no limit
+	CommentDepth int
+	// number of requests per post to load the comments reddit leaves behind "more" stubs, shallowest first. 0 means the digest only has what the post came with
+	CommentExpansions int
+	// subreddits found through other subreddits are collected only if they have at least this many subscribers. defaults to MIN_SUBSCRIBER_LIMIT
+	MinSubscribers int
+	// explore the subreddits reddit lists as similar to the subscribed ones
+	CollectSimilar bool
+	// collect the subreddits without going into their posts e.g. for a daily refresh of the subreddit metadata
+	// the posts are still listed for the digests of the subreddits
+	SubredditsOnly bool
+	// text of an item gets truncated to this length. defaults to MAX_EXTRACTED_TEXT_LENGTH
+	MaxTextLength int
+	// items with less text than this are not stored. defaults to MIN_TEXT_LENGTH
+	MinTextLength int
+	// text of each child in a digest gets truncated to this length. defaults to MAX_CHILD_TEXT_LENGTH
+	MaxChildTextLength int
+	// max number of children in a digest. defaults to MAX_POST_LIMIT
+	DigestSize int
+	// a digest stops taking children after this length. defaults to MAX_DIGEST_TEXT_LENGTH
+	MaxDigestLength int
+	// max number of items read from each history listing (upvoted, saved etc.) of an account. defaults to MAX_PAGE_LIMIT
+	MaxHistoryItems int
+}
+
+// the policy the collector used before it was configurable
+func DefaultCollectionPolicy() CollectionPolicy {
+	return CollectionPolicy{}.withDefaults()
+}
+
+// reports the first setting that makes no sense. zero values are fine since they mean the default
+func (policy CollectionPolicy) Validate() error {
+	for _, setting := range []struct {
+		name  string
+		value int
+	}{
+		{"PostsPerSubreddit", policy.PostsPerSubreddit},
+		{"CommentDepth", policy.CommentDepth},
+		{"CommentExpansions", policy.CommentExpansions},
+		{"MinSubscribers", policy.MinSubscribers},
+		{"MaxTextLength", policy.MaxTextLength},
+		{"MinTextLength", policy.MinTextLength},
+		{"MaxChildTextLength", policy.MaxChildTextLength},
+		{"DigestSize", policy.DigestSize},
+		{"MaxDigestLength", policy.MaxDigestLength},
+		{"MaxHistoryItems", policy.MaxHistoryItems},
+	} {
+		if setting.value < 0 {
+			return fmt.Errorf("CollectionPolicy.%s can't be negative", setting.name)
+		}
+	}
+	if policy.PostsPerSubreddit > MAX_PAGE_LIMIT {
+		return fmt.Errorf("CollectionPolicy.PostsPerSubreddit can't be more than %d", MAX_PAGE_LIMIT)
+	}
+	resolved := policy.withDefaults()
+	if resolved.MinTextLength > resolved.MaxTextLength {
+		return errors.New("CollectionPolicy.MinTextLength can't be more than MaxTextLength")
+	}
+
+	validate_sorts := func(sorts []PostsOptions) error {
+		for _, sort := range sorts {
+			if sort.Sort != "" && !slices.Contains([]string{HOT, TOP, BEST, NEW, RISING, CONTROVERSIAL}, sort.Sort) {
+				return fmt.Errorf("unknown post sort %q", sort.Sort)
+			}
+			if sort.Time != "" && !slices.Contains([]string{HOUR, DAY, WEEK, MONTH, YEAR, ALL_TIME}, sort.Time) {
+				return fmt.Errorf("unknown post time %q", sort.Time)
+			}
+		}
+		return nil
+	}
+	if err := validate_sorts(policy.PostSorts); err != nil {
+		return fmt.Errorf("CollectionPolicy.PostSorts: %w", err)
+	}
+	for subreddit, sorts := range policy.SubredditPostSorts {
+		if err := validate_sorts(sorts); err != nil {
+			return fmt.Errorf("CollectionPolicy.SubredditPostSorts[%s]: %w", subreddit, err)
+		}
+	}
+	return nil
+}
+
+// copy of the policy with the zero values replaced by the defaults
+func (policy CollectionPolicy) withDefaults() CollectionPolicy {
+	default_int := func(value *int, default_value int) {
+		if *value <= 0 {
+			*value = default_value
+		}
+	}
+	default_int(&policy.PostsPerSubreddit, MAX_POST_LIMIT)
+	default_int(&policy.MinSubscribers, MIN_SUBSCRIBER_LIMIT)
+	default_int(&policy.MaxTextLength, MAX_EXTRACTED_TEXT_LENGTH)
+	default_int(&policy.MinTextLength, MIN_TEXT_LENGTH)
+	default_int(&policy.MaxChildTextLength, MAX_CHILD_TEXT_LENGTH)
+	default_int(&policy.DigestSize, MAX_POST_LIMIT)
+	default_int(&policy.MaxDigestLength, MAX_DIGEST_TEXT_LENGTH)
+	default_int(&policy.MaxHistoryItems, MAX_PAGE_LIMIT)
+	if len(policy.PostSorts) == 0 {
+		policy.PostSorts = []PostsOptions{{Sort: HOT}}
+	}
+	return policy
+}
+
+// sorts to pull the posts of the given subreddit with. each is limited to PostsPerSubreddit unless it has its own limit
+func (policy *CollectionPolicy) postSorts(subreddit string) []PostsOptions {
+	sorts := policy.PostSorts
+	if subreddit_sorts, ok := policy.SubredditPostSorts[subreddit]; ok && len(subreddit_sorts) > 0 {
+		sorts = subreddit_sorts
+	}
+	limited := make([]PostsOptions, len(sorts))
+	for i, sort := range sorts {
+		if sort.Limit <= 0 {
+			sort.Limit = policy.PostsPerSubreddit
+		}
+		limited[i] = sort
+	}
+	return limited
+}
@@ -0,0 +1,205 @@
+package sdk
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+const (
+	MORE           = "more" // kind of the stubs reddit puts in place of comments it did not load
+	MAX_MORE_LIMIT = 100    // max number of comment ids /api/morechildren accepts in a single request
+)
+
+// a comment along with its position in the discussion
+type CommentNode struct {
+	Comment RedditItem
+	Depth   int            // 0 for top level comments
+	Parent  *CommentNode   // nil for top level comments
+	Replies []*CommentNode // direct replies in the order reddit returned them
+}
+
+// the discussion under a post
+// reddit only returns part of a large discussion and puts "more" stubs in place of the rest. ExpandMore loads those
+type CommentTree struct {
+	Post     RedditItem
+	Comments []*CommentNode // top level comments
+
+	nodes map[string]*CommentNode // all loaded comments by fullname
+	more  []moreStub              // stubs that have not been expanded yet
+}
+
+// limits on how much of a discussion gets loaded
+type CommentTreeOptions struct {
+	MaxExpansions int // max number of /api/morechildren requests. 0 means "more" stubs are left as is
+	MaxDepth      int // don't expand stubs deeper than this. 0 means no limit
+	MaxComments   int // stop expanding once the tree has this many comments. 0 means no limit
+}
+
+type moreStub struct {
+	ParentId string
+	Depth    int
+	Children []string // ids (not fullnames) of the comments hidden behind this stub
+}
+
+// internal wrapper for comment things. on top of the usual item fields they carry their replies and the "more" stub fields
+type commentThing struct {
+	Kind string `json:"kind"`
+	Data struct {
+		RedditItem
+		ParentId string          `json:"parent_id"`
+		Depth    int             `json:"depth"`
+		Replies  json.RawMessage `json:"replies"`  // empty string when there are no replies, a listing otherwise
+		Children []string        `json:"children"` // only for "more" stubs
+	} `json:"data"`
+}
+
+type commentListing struct {
+	Data struct {
+		Children []commentThing `json:"children"`
+	} `json:"data"`
+}
+
+// loads the discussion under a post and expands "more" stubs within the limits of options
+func (client *RedditClient) CommentTree(post *RedditItem, options CommentTreeOptions) (*CommentTree, error) {
+	return client.CommentTreeContext(context.Background(), post, options)
+}
+
+func (client *RedditClient) CommentTreeContext(ctx context.Context, post *RedditItem, options CommentTreeOptions) (*CommentTree, error) {
+	// this returns 2 listings. the first one has the post itself, the second one has the comments
+	var listings []commentListing
+	if err := client.responseError(client.http_client.R().
+		SetContext(ctx).
+		SetResult(&listings).
+		Get(fmt.Sprintf("/%s/comments/%s", post.SubredditPrefixed, post.Id))); err != nil {
+		return nil, err
+	}
+
+	tree := &CommentTree{
+		Post:  *post,
+		nodes: make(map[string]*CommentNode),
+	}
+	if len(listings) > 1 {
+		for _, thing := range listings[1].Data.Children {
+			tree.add(thing, nil)
+		}
+	}
+	return tree, tree.ExpandMore(ctx, client, options)
+}
+
+// loads the comments hidden behind "more" stubs through /api/morechildren, shallowest stubs first
+// stubs that are left out because of the limits stay in the tree and can be expanded by a later call
+func (tree *CommentTree) ExpandMore(ctx context.Context, client *RedditClient, options CommentTreeOptions) error {
+	var skipped []moreStub
+	for expansions := 0; expansions < options.MaxExpansions && len(tree.more) > 0; {
+		if options.MaxComments > 0 && len(tree.nodes) >= options.MaxComments {
+			break
+		}
+
+		// stubs get appended in the order they are found, which is depth first
+		slices.SortStableFunc(tree.more, func(a, b moreStub) int { return a.Depth - b.Depth })
+		stub := tree.more[0]
+		tree.more = tree.more[1:]
+		if options.MaxDepth > 0 && stub.Depth > options.MaxDepth {
+			skipped = append(skipped, stub)
+			continue
+		}
+		// put back whatever does not fit in one request
+		if len(stub.Children) > MAX_MORE_LIMIT {
+			tree.more = append([]moreStub{{ParentId: stub.ParentId, Depth: stub.Depth, Children: stub.Children[MAX_MORE_LIMIT:]}}, tree.more...)
+			stub.Children = stub.Children[:MAX_MORE_LIMIT]
+		}
+
+		var result struct {
+			Json struct {
+				Data struct {
+					Things []commentThing `json:"things"`
+				} `json:"data"`
+			} `json:"json"`
+		}
+		if err := client.responseError(client.http_client.R().
+			SetContext(ctx).
+			SetQueryParams(map[string]string{
+				"api_type":       "json",
+				"link_id":        tree.Post.Name,
+				"children":       strings.Join(stub.Children, ","),
+				"limit_children": "false",
+			}).
+			SetResult(&result).
+			Get("/api/morechildren")); err != nil {
+			// keep the stub so that the caller can retry
+			tree.more = append([]moreStub{stub}, tree.more...)
+			tree.more = append(tree.more, skipped...)
+			return err
+		}
+		expansions += 1
+
+		// things come back flat in tree order so parents always show up before their replies
+		for _, thing := range result.Json.Data.Things {
+			tree.add(thing, tree.nodes[thing.Data.ParentId])
+		}
+	}
+	tree.more = append(tree.more, skipped...)
+	return nil
+}
+
+// number of comments hidden behind stubs that have not been expanded
+func (tree *CommentTree) MoreCount() int {
+	count := 0
+	for _, stub := range tree.more {
+		count += len(stub.Children)
+	}
+	return count
+}
+
+// all loaded comments, depth first i.e. every comment is followed by its replies
+func (tree *CommentTree) Flatten() []RedditItem {
+	collection := make([]RedditItem, 0, len(tree.nodes))
+	var walk func(nodes []*CommentNode)
+	walk = func(nodes []*CommentNode) {
+		for _, node := range nodes {
+			collection = append(collection, node.Comment)
+			walk(node.Replies)
+		}
+	}
+	walk(tree.Comments)
+	return collection
+}
+
+// adds a comment or a stub under parent along with all the replies nested in it
+func (tree *CommentTree) add(thing commentThing, parent *CommentNode) {
+	switch extractKind(thing.Kind) {
+	case COMMENT:
+		node := &CommentNode{
+			Comment: thing.Data.RedditItem,
+			Depth:   thing.Data.Depth,
+			Parent:  parent,
+		}
+		node.Comment.Kind = COMMENT
+		tree.nodes[node.Comment.Name] = node
+		if parent != nil {
+			parent.Replies = append(parent.Replies, node)
+		} else {
+			tree.Comments = append(tree.Comments, node)
+		}
+
+		// replies is "" when there are none
+		var replies commentListing
+		if json.Unmarshal(thing.Data.Replies, &replies) == nil {
+			for _, reply := range replies.Data.Children {
+				tree.add(reply, node)
+			}
+		}
+	case MORE:
+		// stubs without children are "continue this thread" links which morechildren can't load
+		if len(thing.Data.Children) > 0 {
+			tree.more = append(tree.more, moreStub{
+				ParentId: thing.Data.ParentId,
+				Depth:    thing.Data.Depth,
+				Children: thing.Data.Children,
+			})
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package sdk
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// the expansions go to the top level stubs before the ones deep in a thread
+func TestExpandMoreShallowestFirst(t *testing.T) {
+	var expanded []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		expanded = append(expanded, r.URL.Query().Get("children"))
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"json": {"data": {"things": []}}}`))
+	}))
+	defer server.Close()
+	client := NewAuthenticatedRedditClient(&RedditUser{UserId: "comments-test", AccessToken: "token"}, RedditClientConfig{})
+	client.http_client.SetBaseURL(server.URL)
+
+	// found in this order while walking the first page depth first
+	tree := &CommentTree{
+		Post:  RedditItem{Name: "t3_abc"},
+		nodes: make(map[string]*CommentNode),
+		more: []moreStub{
+			{ParentId: "t1_b", Depth: 2, Children: []string{"deep"}},
+			{ParentId: "t1_a", Depth: 1, Children: []string{"reply"}},
+			{ParentId: "t3_abc", Depth: 0, Children: []string{"top"}},
+		},
+	}
+	if err := tree.ExpandMore(context.Background(), client, CommentTreeOptions{MaxExpansions: 2}); err != nil {
+		t.Fatal(err)
+	}
+	if len(expanded) != 2 || expanded[0] != "top" || expanded[1] != "reply" {
+		t.Errorf("expected the top and reply stubs to be expanded, got %v", expanded)
+	}
+	if tree.MoreCount() != 1 || tree.more[0].Children[0] != "deep" {
+		t.Errorf("expected only the deep stub to be left, got %+v", tree.more)
+	}
+}
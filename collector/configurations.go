@@ -1,10 +1,14 @@
 package sdk
 
 import (
+	"log"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	ds "github.com/soumitsalman/beansack/sdk"
+	oldds "github.com/soumitsalman/media-content-service/api"
 )
 
 const (
@@ -20,9 +24,15 @@ const (
 type RedditClientConfig struct {
 	AppName     string
 	AppId       string
-	AppSecret   string
+	AppSecret   string // leave empty for installed apps
 	RedirectUri string
 	Scope       string
+	DeviceId    string // unique id of the device for app-only auth of installed apps. defaults to DEFAULT_DEVICE_ID
+	// signs and verifies the oauth state. share one across configs so that states generated with one are accepted by the other
+	// defaults to a package wide manager with a random key
+	StateManager *OauthStateManager
+	// paces the requests of all clients created with this config. nil means only reddit's per-account quota applies
+	RequestPacer *RequestPacer
 }
 
 // type BeansackConfig struct {
@@ -34,13 +44,40 @@ type RedditClientConfig struct {
 type CollectorConfig struct {
 	MasterCollectorUsername string
 	MasterCollectorPassword string
+	// collect with an app-only token when there is no master username. this can only read public data
+	MasterCollectorAppOnly bool
 	RedditClientConfig
-	store_func func(beans []ds.Bean)
+	// what gets collected and how much of it. zero values mean the defaults
+	Policy CollectionPolicy
+	// collected once per run with the first account i.e. the master if there is one
+	Targets []CollectionTarget
+	// collect the Targets only and skip the subscriptions of every account
+	TargetsOnly bool
+	// gets the engagements of each linked account after its beans are stored. nil drops them
+	// the master account's engagements are never sent since they are not a real user's
+	EngagementFunc func(engagements []*oldds.UserEngagementItem)
+	// where the linked accounts are kept. defaults to an in-memory store
+	AccountStore AccountStore
+	// number of accounts collected at the same time. defaults to 1
+	UserConcurrency int
+	// number of subreddits and posts of an account collected at the same time. defaults to 1
+	ItemConcurrency int
+	// where each run records how far it got so that the next one only collects new posts. nil means everything is collected every run
+	CheckpointStore CheckpointStore
+	// posts collected by earlier runs are still refreshed while they are younger than this. defaults to DEFAULT_FRESHNESS_WINDOW
+	FreshnessWindow time.Duration
+	// where the collected beans go
+	BeanSink BeanSink
+	// how the beans are handed to the BeanSink. by default all the beans of an account go in one call with MAX_REQUEST_RETRIES retries
+	SinkOptions SinkOptions
+	// what Run collects and how often. defaults to everything every DEFAULT_COLLECTION_INTERVAL
+	Schedules []CollectionSchedule
 }
 
 const (
-	DEFAULT_USERID = "__BLANK__"
-	SCOPE          = "identity read mysubreddits"
+	DEFAULT_USERID    = "__BLANK__"
+	SCOPE             = "identity read mysubreddits history submit"
+	DEFAULT_DEVICE_ID = "DO_NOT_TRACK_THIS_DEVICE" // reddit's designated device id for clients that don't want to be tracked
 )
 
 func getAppName() string {
@@ -67,6 +104,128 @@ func getMasterPassword() string {
 	return os.Getenv("REDDITOR_MASTER_USER_PW")
 }
 
+func getMasterAppOnly() bool {
+	app_only, _ := strconv.ParseBool(os.Getenv("REDDITOR_MASTER_APP_ONLY"))
+	return app_only
+}
+
+func getDeviceId() string {
+	return os.Getenv("REDDITOR_DEVICE_ID")
+}
+
+func getOauthStateSecret() []byte {
+	return []byte(os.Getenv("REDDITOR_OAUTH_STATE_SECRET"))
+}
+
+func getUserConcurrency() int {
+	concurrency, _ := strconv.Atoi(os.Getenv("REDDITOR_USER_CONCURRENCY"))
+	return concurrency
+}
+
+func getItemConcurrency() int {
+	concurrency, _ := strconv.Atoi(os.Getenv("REDDITOR_ITEM_CONCURRENCY"))
+	return concurrency
+}
+
+// shared by every client the collector creates if REDDITOR_MAX_REQUESTS_PER_SECOND is set
+func getRequestPacer() *RequestPacer {
+	requests_per_second, _ := strconv.ParseFloat(os.Getenv("REDDITOR_MAX_REQUESTS_PER_SECOND"), 64)
+	if requests_per_second <= 0 {
+		return nil
+	}
+	return NewRequestPacer(requests_per_second)
+}
+
+func getAccountsFile() string {
+	return os.Getenv("REDDITOR_ACCOUNTS_FILE")
+}
+
+func getAccountsKey() string {
+	return os.Getenv("REDDITOR_ACCOUNTS_KEY")
+}
+
+func getAccountsOldKeys() string {
+	return os.Getenv("REDDITOR_ACCOUNTS_OLD_KEYS")
+}
+
+// linked accounts go to an encrypted file if REDDITOR_ACCOUNTS_FILE is set or else they stay in memory
+func getAccountStore() AccountStore {
+	if getAccountsFile() == "" {
+		return nil
+	}
+	credential_cipher, err := NewCredentialCipherFromEnv()
+	if err != nil {
+		log.Println("Not persisting accounts.", err)
+		return nil
+	}
+	store, err := NewFileAccountStore(getAccountsFile(), credential_cipher)
+	if err != nil {
+		log.Println("Not persisting accounts.", err)
+		return nil
+	}
+	return store
+}
+
+// comma separated short forms of ParseCollectionTarget e.g. "r/golang,u/someone,search:generics"
+// targets that don't parse are logged and skipped
+func getTargets() []CollectionTarget {
+	var targets []CollectionTarget
+	for _, value := range strings.Split(os.Getenv("REDDITOR_TARGETS"), ",") {
+		if strings.TrimSpace(value) == "" {
+			continue
+		}
+		target, err := ParseCollectionTarget(value)
+		if err != nil {
+			log.Println("Skipping target.", err)
+			continue
+		}
+		targets = append(targets, target)
+	}
+	return targets
+}
+
+func getTargetsOnly() bool {
+	targets_only, _ := strconv.ParseBool(os.Getenv("REDDITOR_TARGETS_ONLY"))
+	return targets_only
+}
+
+// batches that the sink keeps failing to store go here if it is set
+func getDeadLetterDir() string {
+	return os.Getenv("REDDITOR_DEAD_LETTER_DIR")
+}
+
+func getCheckpointsFile() string {
+	return os.Getenv("REDDITOR_CHECKPOINTS_FILE")
+}
+
+// checkpoints go to a file if REDDITOR_CHECKPOINTS_FILE is set or else runs don't keep track of each other
+func getCheckpointStore() CheckpointStore {
+	if getCheckpointsFile() == "" {
+		return nil
+	}
+	store, err := NewFileCheckpointStore(getCheckpointsFile())
+	if err != nil {
+		log.Println("Not using checkpoints.", err)
+		return nil
+	}
+	return store
+}
+
+// duration like "12h". empty or invalid means the default
+func getFreshnessWindow() time.Duration {
+	window, _ := time.ParseDuration(os.Getenv("REDDITOR_FRESHNESS_WINDOW"))
+	return window
+}
+
+// a single default schedule every REDDITOR_COLLECTION_INTERVAL e.g. "30m" if it is set
+func getSchedules() []CollectionSchedule {
+	interval, _ := time.ParseDuration(os.Getenv("REDDITOR_COLLECTION_INTERVAL"))
+	if interval <= 0 {
+		return nil
+	}
+	return []CollectionSchedule{{Name: DEFAULT_SCHEDULE, Interval: interval}}
+}
+
 // func getBeanUrl() string {
 // 	return os.Getenv("BEANSACK_URL")
 // }
@@ -75,6 +234,7 @@ func getMasterPassword() string {
 // 	return os.Getenv("INTERNAL_AUTH_TOKEN")
 // }
 
+// store_func can't report failures. replace BeanSink with one that can for the retries and dead letters to kick in
 func NewCollectorConfig(store_func func(beans []ds.Bean)) CollectorConfig {
 	return CollectorConfig{
 		// BeansackConfig: BeansackConfig{
@@ -84,13 +244,28 @@ func NewCollectorConfig(store_func func(beans []ds.Bean)) CollectorConfig {
 		// },
 		MasterCollectorUsername: getMasterUsername(),
 		MasterCollectorPassword: getMasterPassword(),
+		MasterCollectorAppOnly:  getMasterAppOnly(),
 		RedditClientConfig: RedditClientConfig{
 			AppName:     getAppName(),
 			AppId:       getAppId(),
 			AppSecret:   getAppSecret(),
 			RedirectUri: getOauthRedirectUri(),
 			Scope:       SCOPE,
+			DeviceId:    getDeviceId(),
+			// set REDDITOR_OAUTH_STATE_SECRET when running multiple instances so that all of them accept each other's states
+			StateManager: NewOauthStateManager(getOauthStateSecret(), OAUTH_STATE_TTL),
+			RequestPacer: getRequestPacer(),
 		},
-		store_func: store_func,
+		Policy:          DefaultCollectionPolicy(),
+		Targets:         getTargets(),
+		TargetsOnly:     getTargetsOnly(),
+		AccountStore:    getAccountStore(),
+		UserConcurrency: getUserConcurrency(),
+		ItemConcurrency: getItemConcurrency(),
+		CheckpointStore: getCheckpointStore(),
+		FreshnessWindow: getFreshnessWindow(),
+		BeanSink:        StoreFunc(store_func),
+		SinkOptions:     SinkOptions{DeadLetterDir: getDeadLetterDir()},
+		Schedules:       getSchedules(),
 	}
 }
@@ -0,0 +1,152 @@
+package sdk
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	// prefix of encrypted values followed by <key id>:<base64 of nonce + ciphertext>
+	ENCRYPTED_VALUE_PREFIX = "enc:v2:"
+	// values written before they were bound to their account and field. Reencrypt turns them into ENCRYPTED_VALUE_PREFIX ones
+	LEGACY_ENCRYPTED_VALUE_PREFIX = "enc:v1:"
+)
+
+var ErrUnknownKey = errors.New("value is encrypted with an unknown key")
+
+// encrypts credentials with AES-GCM before they are persisted
+// the first key encrypts. every key is tried for decryption so that values written before a key rotation stay readable
+type CredentialCipher struct {
+	keys []cipherKey
+}
+
+type cipherKey struct {
+	id   string // derived from the key so that a value can tell which key it was encrypted with
+	aead cipher.AEAD
+}
+
+// each key has to be 16, 24 or 32 bytes long for AES-128, AES-192 or AES-256
+func NewCredentialCipher(primary_key []byte, old_keys ...[]byte) (*CredentialCipher, error) {
+	var credential_cipher CredentialCipher
+	for _, key := range append([][]byte{primary_key}, old_keys...) {
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+		hash := sha256.Sum256(key)
+		credential_cipher.keys = append(credential_cipher.keys, cipherKey{id: hex.EncodeToString(hash[:4]), aead: aead})
+	}
+	return &credential_cipher, nil
+}
+
+// reads base64 encoded keys from REDDITOR_ACCOUNTS_KEY and the comma separated REDDITOR_ACCOUNTS_OLD_KEYS
+func NewCredentialCipherFromEnv() (*CredentialCipher, error) {
+	primary_key, err := base64.StdEncoding.DecodeString(getAccountsKey())
+	if err != nil || len(primary_key) == 0 {
+		return nil, fmt.Errorf("REDDITOR_ACCOUNTS_KEY needs to be a base64 encoded 16, 24 or 32 byte key")
+	}
+	var old_keys [][]byte
+	for _, encoded := range strings.Split(getAccountsOldKeys(), ",") {
+		if encoded = strings.TrimSpace(encoded); encoded == "" {
+			continue
+		}
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("REDDITOR_ACCOUNTS_OLD_KEYS: %w", err)
+		}
+		old_keys = append(old_keys, key)
+	}
+	return NewCredentialCipher(primary_key, old_keys...)
+}
+
+// empty values stay empty so that it is still visible which credentials an account has
+// associated_data is authenticated along with the value but not stored. Decrypt fails unless it gets the same
+// bind the value to where it belongs e.g. the account and field so that it can't be moved elsewhere in the file
+func (credential_cipher *CredentialCipher) Encrypt(plaintext, associated_data string) (string, error) {
+	if plaintext == "" {
+		return "", nil
+	}
+	key := credential_cipher.keys[0]
+	nonce := make([]byte, key.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := key.aead.Seal(nonce, nonce, []byte(plaintext), []byte(associated_data))
+	return ENCRYPTED_VALUE_PREFIX + key.id + ":" + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// values without an encrypted value prefix are returned as is so that files written before encryption was turned on still load
+// LEGACY_ENCRYPTED_VALUE_PREFIX values are decrypted without the associated data since they were written without it
+func (credential_cipher *CredentialCipher) Decrypt(value, associated_data string) (string, error) {
+	var encrypted string
+	if after, ok := strings.CutPrefix(value, ENCRYPTED_VALUE_PREFIX); ok {
+		encrypted = after
+	} else if after, ok := strings.CutPrefix(value, LEGACY_ENCRYPTED_VALUE_PREFIX); ok {
+		encrypted, associated_data = after, ""
+	} else {
+		return value, nil
+	}
+	key_id, encoded, found := strings.Cut(encrypted, ":")
+	if !found {
+		return "", fmt.Errorf("malformed encrypted value")
+	}
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", err
+	}
+	for _, key := range credential_cipher.keys {
+		if key.id != key_id {
+			continue
+		}
+		if len(sealed) < key.aead.NonceSize() {
+			return "", fmt.Errorf("malformed encrypted value")
+		}
+		nonce, ciphertext := sealed[:key.aead.NonceSize()], sealed[key.aead.NonceSize():]
+		var additional []byte
+		if associated_data != "" {
+			additional = []byte(associated_data)
+		}
+		plaintext, err := key.aead.Open(nil, nonce, ciphertext, additional)
+		if err != nil {
+			return "", err
+		}
+		return string(plaintext), nil
+	}
+	return "", ErrUnknownKey
+}
+
+// encrypts or decrypts all the credential fields of the user
+// each value is bound to the user id and the field so that values swapped between accounts or fields fail to decrypt
+func (credential_cipher *CredentialCipher) transformCredentials(user *RedditUser, transform func(value, associated_data string) (string, error)) error {
+	for _, field := range []struct {
+		name  string
+		value *string
+	}{
+		{"password", &user.Password},
+		{"access_token", &user.AccessToken},
+		{"refresh_token", &user.RefreshToken},
+	} {
+		value, err := transform(*field.value, credentialAssociatedData(user.UserId, field.name))
+		if err != nil {
+			return fmt.Errorf("account %s %s: %w", user.UserId, field.name, err)
+		}
+		*field.value = value
+	}
+	return nil
+}
+
+// the field names are fixed and have no NUL in them so no other user id and field come out the same
+func credentialAssociatedData(user_id, field string) string {
+	return field + "\x00" + user_id
+}
@@ -0,0 +1,141 @@
+package sdk
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var (
+	test_key     = bytes.Repeat([]byte{1}, 32)
+	test_old_key = bytes.Repeat([]byte{2}, 32)
+)
+
+func TestCredentialCipherRoundTrip(t *testing.T) {
+	credential_cipher, err := NewCredentialCipher(test_key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encrypted, err := credential_cipher.Encrypt("secret", "refresh_token\x00someone")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(encrypted, ENCRYPTED_VALUE_PREFIX) || strings.Contains(encrypted, "secret") {
+		t.Fatalf("expected an encrypted value, got %s", encrypted)
+	}
+	if decrypted, err := credential_cipher.Decrypt(encrypted, "refresh_token\x00someone"); err != nil || decrypted != "secret" {
+		t.Errorf("expected secret, got %q and %v", decrypted, err)
+	}
+	if empty, err := credential_cipher.Encrypt("", "refresh_token\x00someone"); err != nil || empty != "" {
+		t.Errorf("expected empty values to stay empty, got %q and %v", empty, err)
+	}
+	if plaintext, err := credential_cipher.Decrypt("not encrypted", ""); err != nil || plaintext != "not encrypted" {
+		t.Errorf("expected values from before encryption as is, got %q and %v", plaintext, err)
+	}
+}
+
+// values moved to another account or another field of the same account must not decrypt
+func TestCredentialCipherBindsValues(t *testing.T) {
+	credential_cipher, _ := NewCredentialCipher(test_key)
+	user := RedditUser{UserId: "someone", Password: "password", RefreshToken: "refresh"}
+	if err := credential_cipher.transformCredentials(&user, credential_cipher.Encrypt); err != nil {
+		t.Fatal(err)
+	}
+
+	swapped_fields := user
+	swapped_fields.Password, swapped_fields.RefreshToken = user.RefreshToken, user.Password
+	if err := credential_cipher.transformCredentials(&swapped_fields, credential_cipher.Decrypt); err == nil {
+		t.Error("expected values swapped between fields to fail")
+	}
+	other_account := user
+	other_account.UserId = "attacker"
+	if err := credential_cipher.transformCredentials(&other_account, credential_cipher.Decrypt); err == nil {
+		t.Error("expected values moved to another account to fail")
+	}
+	if err := credential_cipher.transformCredentials(&user, credential_cipher.Decrypt); err != nil || user.Password != "password" || user.RefreshToken != "refresh" {
+		t.Errorf("expected the original values, got %+v and %v", user, err)
+	}
+}
+
+func TestCredentialCipherKeyRotation(t *testing.T) {
+	old_cipher, _ := NewCredentialCipher(test_old_key)
+	encrypted, _ := old_cipher.Encrypt("secret", "password\x00someone")
+
+	rotated, err := NewCredentialCipher(test_key, test_old_key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decrypted, err := rotated.Decrypt(encrypted, "password\x00someone"); err != nil || decrypted != "secret" {
+		t.Errorf("expected the old key to still decrypt, got %q and %v", decrypted, err)
+	}
+	without_old_key, _ := NewCredentialCipher(test_key)
+	if _, err := without_old_key.Decrypt(encrypted, "password\x00someone"); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("expected ErrUnknownKey, got %v", err)
+	}
+}
+
+// Reencrypt rewrites values of old keys and legacy values with the primary key
+func TestFileAccountStoreReencrypt(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "accounts.json")
+	old_cipher, _ := NewCredentialCipher(test_old_key)
+	old_store, err := NewFileAccountStore(path, old_cipher)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := old_store.Put(RedditUser{UserId: "someone", RefreshToken: "refresh"}); err != nil {
+		t.Fatal(err)
+	}
+	// a value written before values were bound to their account and field
+	legacy, _ := old_cipher.Encrypt("password", "")
+	legacy = LEGACY_ENCRYPTED_VALUE_PREFIX + strings.TrimPrefix(legacy, ENCRYPTED_VALUE_PREFIX)
+	var users []RedditUser
+	data, _ := os.ReadFile(path)
+	json.Unmarshal(data, &users)
+	users[0].Password = legacy
+	data, _ = json.Marshal(users)
+	os.WriteFile(path, data, 0600)
+
+	rotated, _ := NewCredentialCipher(test_key, test_old_key)
+	store, err := NewFileAccountStore(path, rotated)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Reencrypt(); err != nil {
+		t.Fatal(err)
+	}
+	data, _ = os.ReadFile(path)
+	json.Unmarshal(data, &users)
+	primary_id := rotated.keys[0].id
+	for _, value := range []string{users[0].Password, users[0].RefreshToken} {
+		if !strings.HasPrefix(value, ENCRYPTED_VALUE_PREFIX+primary_id+":") {
+			t.Errorf("expected a value encrypted with the primary key, got %s", value)
+		}
+	}
+
+	primary_only, _ := NewCredentialCipher(test_key)
+	reloaded, err := NewFileAccountStore(path, primary_only)
+	if err != nil {
+		t.Fatal(err)
+	}
+	user, _ := reloaded.Get("someone")
+	if user == nil || user.Password != "password" || user.RefreshToken != "refresh" {
+		t.Errorf("expected the credentials with the primary key alone, got %+v", user)
+	}
+}
+
+func TestCredentialCipherFromEnv(t *testing.T) {
+	t.Setenv("REDDITOR_ACCOUNTS_KEY", base64.StdEncoding.EncodeToString(test_key))
+	t.Setenv("REDDITOR_ACCOUNTS_OLD_KEYS", base64.StdEncoding.EncodeToString(test_old_key))
+	credential_cipher, err := NewCredentialCipherFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(credential_cipher.keys) != 2 {
+		t.Errorf("expected 2 keys, got %d", len(credential_cipher.keys))
+	}
+}
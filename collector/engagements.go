@@ -0,0 +1,147 @@
+package sdk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	oldds "github.com/soumitsalman/media-content-service/api"
+)
+
+// actions of UserEngagementItem
+const (
+	JOINED    = "joined"
+	AUTHORED  = "authored"
+	UPVOTED   = "upvoted"
+	DOWNVOTED = "downvoted"
+	SAVED     = "saved"
+	HIDDEN    = "hidden"
+	COMMENTED = "commented"
+	MODERATED = "moderated"
+)
+
+// history listings of the account. all but HISTORY_COMMENTS need the history scope
+const (
+	HISTORY_UPVOTED   = "upvoted"
+	HISTORY_DOWNVOTED = "downvoted"
+	HISTORY_SAVED     = "saved"
+	HISTORY_HIDDEN    = "hidden"
+	HISTORY_COMMENTS  = "comments"
+)
+
+// gets the items the user in the client has upvoted, downvoted, saved, hidden or commented depending on history
+// saved can have both posts and comments. the rest are posts except for HISTORY_COMMENTS
+func (client *RedditClient) History(history string, options ListingOptions) (Listing, error) {
+	return client.HistoryContext(context.Background(), history, options)
+}
+
+func (client *RedditClient) HistoryContext(ctx context.Context, history string, options ListingOptions) (Listing, error) {
+	return client.listing(ctx, fmt.Sprintf("/user/%s/%s", client.User.Username, history), nil, "*", options)
+}
+
+// gets the subreddits the user in the client moderates
+func (client *RedditClient) ModeratedSubreddits(options ListingOptions) (Listing, error) {
+	return client.ModeratedSubredditsContext(context.Background(), options)
+}
+
+func (client *RedditClient) ModeratedSubredditsContext(ctx context.Context, options ListingOptions) (Listing, error) {
+	return client.listing(ctx, "/subreddits/mine/moderator", nil, SUBREDDIT, options)
+}
+
+// engagements of the user with the item that show in the item itself
+// listings fetched with the user's token carry the user's votes, saves and hides
+func (item *RedditItem) toUserEngagements(user *RedditUser) []*oldds.UserEngagementItem {
+	var actions []string
+	switch item.Kind {
+	case SUBREDDIT:
+		if item.UserIsContributor || item.UserIsSubscriber || item.UserIsModerator {
+			actions = append(actions, JOINED)
+		}
+		if item.UserIsModerator {
+			actions = append(actions, MODERATED)
+		}
+	default:
+		if user.Username == item.Author {
+			actions = append(actions, AUTHORED)
+		}
+		if item.Likes != nil && *item.Likes {
+			actions = append(actions, UPVOTED)
+		} else if item.Likes != nil {
+			actions = append(actions, DOWNVOTED)
+		}
+		if item.Saved {
+			actions = append(actions, SAVED)
+		}
+		if item.Hidden {
+			actions = append(actions, HIDDEN)
+		}
+	}
+
+	engagements := make([]*oldds.UserEngagementItem, len(actions))
+	for i, action := range actions {
+		engagements[i] = newUserEngagement(user, item.Name, action)
+	}
+	return engagements
+}
+
+// copy of the item with the flags of the token that fetched it cleared. only AUTHORED is left for toUserEngagements
+func (item *RedditItem) withoutUserFlags() *RedditItem {
+	stripped := *item
+	stripped.UserIsSubscriber, stripped.UserIsModerator, stripped.UserIsContributor = false, false, false
+	stripped.Likes, stripped.Saved, stripped.Hidden = nil, false, false
+	return &stripped
+}
+
+func newUserEngagement(user *RedditUser, content_id, action string) *oldds.UserEngagementItem {
+	return &oldds.UserEngagementItem{
+		Username:   user.Username,
+		UserSource: REDDIT_SOURCE,
+		Source:     REDDIT_SOURCE,
+		ContentId:  content_id,
+		Action:     action,
+	}
+}
+
+// walks the history listings of the account for up to max_items each
+// accounts linked before the history scope was asked for get a 403 on those. they are logged and skipped instead of failing the collection
+func collectHistoryEngagements(ctx context.Context, client *RedditClient, max_items int) ([]*oldds.UserEngagementItem, error) {
+	var engagements []*oldds.UserEngagementItem
+	var errs []error
+	for _, history := range []struct {
+		name   string
+		action string
+		fetch  func(ListingOptions) (Listing, error)
+	}{
+		{HISTORY_UPVOTED, UPVOTED, nil},
+		{HISTORY_DOWNVOTED, DOWNVOTED, nil},
+		{HISTORY_SAVED, SAVED, nil},
+		{HISTORY_HIDDEN, HIDDEN, nil},
+		{HISTORY_COMMENTS, COMMENTED, nil},
+		{"moderator", MODERATED, func(options ListingOptions) (Listing, error) { return client.ModeratedSubredditsContext(ctx, options) }},
+	} {
+		if ctx.Err() != nil {
+			break
+		}
+		if history.fetch == nil {
+			history.fetch = func(options ListingOptions) (Listing, error) {
+				return client.HistoryContext(ctx, history.name, options)
+			}
+		}
+		items, err := NewListingIterator(history.fetch, ListingOptions{Limit: min(max_items, MAX_PAGE_LIMIT)}, max_items).All()
+		if errors.Is(err, ErrForbidden) {
+			log.Printf("No %s history for u/%s. The account needs to be linked again with the history scope\n", history.name, client.User.Username)
+		} else if err != nil {
+			errs = append(errs, fmt.Errorf("%s history: %w", history.name, err))
+		}
+		for _, item := range items {
+			content_id := item.Name
+			if history.action == COMMENTED {
+				// the engagement is with the post the comment was made in
+				content_id = item.Parent
+			}
+			engagements = append(engagements, newUserEngagement(client.User, content_id, history.action))
+		}
+	}
+	return engagements, errors.Join(errs...)
+}
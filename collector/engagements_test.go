@@ -0,0 +1,24 @@
+package sdk
+
+import "testing"
+
+// items that came through another account only tell whether the user authored them
+func TestWithoutUserFlags(t *testing.T) {
+	liked := true
+	user := &RedditUser{Username: "someone"}
+	post := RedditItem{Kind: POST, Name: "t3_abc", Author: "someone", Likes: &liked, Saved: true, Hidden: true}
+	if got := len(post.toUserEngagements(user)); got != 4 {
+		t.Fatalf("expected 4 engagements from the user's own item, got %d", got)
+	}
+	engagements := post.withoutUserFlags().toUserEngagements(user)
+	if len(engagements) != 1 || engagements[0].Action != AUTHORED {
+		t.Errorf("expected only %s, got %+v", AUTHORED, engagements)
+	}
+	subreddit := RedditItem{Kind: SUBREDDIT, Name: "t5_abc", UserIsSubscriber: true, UserIsModerator: true}
+	if engagements := subreddit.withoutUserFlags().toUserEngagements(user); len(engagements) != 0 {
+		t.Errorf("expected no engagements, got %+v", engagements)
+	}
+	if post.Likes == nil || !post.Saved {
+		t.Error("the original item must keep its flags")
+	}
+}
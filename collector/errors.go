@@ -0,0 +1,148 @@
+package sdk
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// sentinels to check RedditAPIError against with errors.Is
+var (
+	ErrUnauthorized = errors.New("reddit: unauthorized")      // token is missing, expired or revoked
+	ErrForbidden    = errors.New("reddit: forbidden")         // token lacks the scope or the user lacks access
+	ErrNotFound     = errors.New("reddit: not found")         // item or subreddit does not exist
+	ErrRateLimited  = errors.New("reddit: rate limited")      // too many requests. check RateLimit.Reset
+	ErrBanned       = errors.New("reddit: subreddit banned")  // subreddit got banned by reddit
+	ErrPrivate      = errors.New("reddit: subreddit private") // subreddit is private and the user is not approved
+)
+
+// error returned by RedditClient methods when reddit responds with a non-2xx status
+type RedditAPIError struct {
+	StatusCode int            // http status code of the response
+	ErrorCode  string         // reddit's `error` field. sometimes the status code, sometimes a string like "invalid_grant"
+	Message    string         // reddit's `message` field or the http status text if the body has none
+	Reason     string         // reddit's `reason` field e.g. "private", "banned", "quarantined"
+	RateLimit  RateLimitState // quota at the time of the response
+}
+
+func (err *RedditAPIError) Error() string {
+	msg := fmt.Sprintf("reddit api error %d: %s", err.StatusCode, err.Message)
+	if err.Reason != "" {
+		msg += " (" + err.Reason + ")"
+	}
+	return msg
+}
+
+func (err *RedditAPIError) Is(target error) bool {
+	switch target {
+	case ErrUnauthorized:
+		return err.StatusCode == http.StatusUnauthorized
+	case ErrForbidden:
+		return err.StatusCode == http.StatusForbidden
+	case ErrNotFound:
+		return err.StatusCode == http.StatusNotFound
+	case ErrRateLimited:
+		return err.StatusCode == http.StatusTooManyRequests
+	case ErrBanned:
+		return err.Reason == "banned"
+	case ErrPrivate:
+		return err.Reason == "private"
+	}
+	return false
+}
+
+// true if the same request may succeed later i.e. rate limiting and server side failures
+func (err *RedditAPIError) Retryable() bool {
+	return err.StatusCode == http.StatusTooManyRequests || err.StatusCode >= http.StatusInternalServerError
+}
+
+// only bad, missing or revoked credentials are unauthorized. reddit answers those with a 400 or 401
+// or with a 200 and no token for a wrong password. a 429 or 5xx of the token endpoint is an outage and says nothing about the credentials
+func (res RedditAuthenticationResult) Is(target error) bool {
+	switch target {
+	case ErrUnauthorized:
+		return res.StatusCode == 0 || res.StatusCode == http.StatusOK || res.StatusCode == http.StatusBadRequest || res.StatusCode == http.StatusUnauthorized
+	case ErrRateLimited:
+		return res.StatusCode == http.StatusTooManyRequests
+	}
+	return false
+}
+
+// turns a failed request or a non-2xx response into an error
+func (client *RedditClient) responseError(resp *resty.Response, err error) error {
+	if err != nil {
+		return err
+	}
+	if !resp.IsError() {
+		return nil
+	}
+
+	api_err := &RedditAPIError{
+		StatusCode: resp.StatusCode(),
+		Message:    http.StatusText(resp.StatusCode()),
+		RateLimit:  client.RateLimit(),
+	}
+	// the body is json for most endpoints but can be html when reddit itself is down
+	var body struct {
+		Error   json.RawMessage `json:"error"`
+		Message string          `json:"message"`
+		Reason  string          `json:"reason"`
+	}
+	if json.Unmarshal(resp.Body(), &body) == nil {
+		api_err.ErrorCode = strings.Trim(string(body.Error), `"`)
+		api_err.Reason = body.Reason
+		if body.Message != "" {
+			api_err.Message = body.Message
+		}
+	}
+	return api_err
+}
+
+// error returned by the write methods when reddit turns the request down in json.errors
+// those come with a 200 so they don't show up as a RedditAPIError
+type RedditFormError struct {
+	Errors []RedditFieldError
+}
+
+// one entry of json.errors
+type RedditFieldError struct {
+	Code    string // e.g. "SUBREDDIT_NOEXIST", "RATELIMIT", "NO_TEXT"
+	Message string
+	Field   string // name of the form field the error is about. empty if it is about the whole request
+}
+
+func (err *RedditFormError) Error() string {
+	msgs := make([]string, len(err.Errors))
+	for i, field_err := range err.Errors {
+		msgs[i] = field_err.Code + ": " + field_err.Message
+		if field_err.Field != "" {
+			msgs[i] += " (" + field_err.Field + ")"
+		}
+	}
+	return "reddit rejected the request: " + strings.Join(msgs, "; ")
+}
+
+// reddit's "you are doing that too much" comes as a RATELIMIT entry instead of a 429
+func (err *RedditFormError) Is(target error) bool {
+	return target == ErrRateLimited && slices.ContainsFunc(err.Errors, func(field_err RedditFieldError) bool {
+		return field_err.Code == "RATELIMIT"
+	})
+}
+
+// json.errors is a list of [code, message, field] arrays
+func formError(raw [][]string) error {
+	if len(raw) == 0 {
+		return nil
+	}
+	errs := make([]RedditFieldError, len(raw))
+	for i, entry := range raw {
+		entry = append(entry, "", "", "")
+		errs[i] = RedditFieldError{Code: entry[0], Message: entry[1], Field: entry[2]}
+	}
+	return &RedditFormError{Errors: errs}
+}
@@ -0,0 +1,32 @@
+package sdk
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+)
+
+// outages of the token endpoint must not look like revoked credentials
+func TestAuthenticationResultIs(t *testing.T) {
+	for _, test := range []struct {
+		status       int
+		unauthorized bool
+		rate_limited bool
+	}{
+		{0, true, false},
+		{http.StatusOK, true, false},
+		{http.StatusBadRequest, true, false},
+		{http.StatusUnauthorized, true, false},
+		{http.StatusTooManyRequests, false, true},
+		{http.StatusInternalServerError, false, false},
+		{http.StatusServiceUnavailable, false, false},
+	} {
+		var err error = &RedditAuthenticationResult{StatusCode: test.status, FailureMessage: "failed"}
+		if got := errors.Is(err, ErrUnauthorized); got != test.unauthorized {
+			t.Errorf("status %d: expected ErrUnauthorized %v, got %v", test.status, test.unauthorized, got)
+		}
+		if got := errors.Is(err, ErrRateLimited); got != test.rate_limited {
+			t.Errorf("status %d: expected ErrRateLimited %v, got %v", test.status, test.rate_limited, got)
+		}
+	}
+}
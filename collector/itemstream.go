@@ -0,0 +1,201 @@
+package sdk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	DEFAULT_MIN_POLL_INTERVAL = 5 * time.Second
+	DEFAULT_MAX_POLL_INTERVAL = 2 * time.Minute
+	DEFAULT_SEEN_LIMIT        = 1000 // enough to cover several full pages of the busiest listings
+)
+
+// how StreamPosts and StreamComments poll
+type StreamOptions struct {
+	// the wait between polls halves down to MinInterval while polls find new items and grows up to MaxInterval while they don't
+	// they default to DEFAULT_MIN_POLL_INTERVAL and DEFAULT_MAX_POLL_INTERVAL
+	MinInterval time.Duration
+	MaxInterval time.Duration
+	// number of fullnames remembered to tell new items from the ones already sent. the oldest are forgotten first. defaults to DEFAULT_SEEN_LIMIT
+	// it is never less than MAX_PAGE_LIMIT since every poll reads a full page and the set has to hold all of it
+	SeenLimit int
+	// don't send the items that are already there on the first poll, only the ones that show up after
+	SkipExisting bool
+	// number of items that can wait in Items before polling pauses
+	Buffer int
+}
+
+// new posts or comments as they show up in a listing
+type ItemStream struct {
+	// oldest first. closed when the context is cancelled or polling fails for good
+	Items <-chan RedditItem
+
+	items chan RedditItem
+	done  chan struct{}
+	err   error
+}
+
+// waits for the stream to be over and returns why it stopped. the context's error if it was cancelled
+// Items has to be drained for the stream to be over
+func (stream *ItemStream) Err() error {
+	<-stream.done
+	return stream.err
+}
+
+// polls /r/{subreddits}/new and sends each new post until ctx is done
+// several subreddits are combined into one listing e.g. StreamPosts(ctx, options, "golang", "rust") polls r/golang+rust
+func (client *RedditClient) StreamPosts(ctx context.Context, options StreamOptions, subreddits ...string) *ItemStream {
+	return client.streamItems(ctx, options, subreddits, NEW, POST)
+}
+
+// polls /r/{subreddits}/comments and sends each new comment until ctx is done
+// several subreddits are combined into one listing the same way as StreamPosts
+func (client *RedditClient) StreamComments(ctx context.Context, options StreamOptions, subreddits ...string) *ItemStream {
+	return client.streamItems(ctx, options, subreddits, COMMENTS, COMMENT)
+}
+
+func (client *RedditClient) streamItems(ctx context.Context, options StreamOptions, subreddits []string, sort, kind string) *ItemStream {
+	items := make(chan RedditItem, max(options.Buffer, 0))
+	stream := &ItemStream{
+		Items: items,
+		items: items,
+		done:  make(chan struct{}),
+	}
+	go func() {
+		defer close(stream.done)
+		defer close(stream.items)
+		stream.err = client.poll(ctx, options.withDefaults(), subreddits, sort, kind, stream.items)
+	}()
+	return stream
+}
+
+func (options StreamOptions) withDefaults() StreamOptions {
+	if options.MinInterval <= 0 {
+		options.MinInterval = DEFAULT_MIN_POLL_INTERVAL
+	}
+	if options.MaxInterval < options.MinInterval {
+		options.MaxInterval = max(DEFAULT_MAX_POLL_INTERVAL, options.MinInterval)
+	}
+	if options.SeenLimit <= 0 {
+		options.SeenLimit = DEFAULT_SEEN_LIMIT
+	}
+	// a smaller set would push out the newest items of a page while reading the older ones and send them again on the next poll
+	options.SeenLimit = max(options.SeenLimit, MAX_PAGE_LIMIT)
+	return options
+}
+
+// "r/golang", "golang" and "golang+rust" all work. an empty list polls r/all
+func subredditPath(subreddits []string) string {
+	var names []string
+	for _, subreddit := range subreddits {
+		for _, name := range strings.Split(subreddit, "+") {
+			name = strings.TrimPrefix(strings.TrimSpace(name), "r/")
+			if name != "" && !slices.Contains(names, name) {
+				names = append(names, name)
+			}
+		}
+	}
+	if len(names) == 0 {
+		return "r/all"
+	}
+	return "r/" + strings.Join(names, "+")
+}
+
+// polls until ctx is done or reddit turns the listing down for good
+// rate limiting and server failures left after the client's own retries only slow the polling down
+func (client *RedditClient) poll(ctx context.Context, options StreamOptions, subreddits []string, sort, kind string, items chan<- RedditItem) error {
+	url := fmt.Sprintf("/%s/%s", subredditPath(subreddits), sort)
+	seen := newSeenSet(options.SeenLimit)
+	interval := options.MinInterval
+	first := true
+	for {
+		listing, err := client.listing(ctx, url, nil, kind, ListingOptions{Limit: MAX_PAGE_LIMIT})
+		var api_err *RedditAPIError
+		switch {
+		case ctx.Err() != nil:
+			return ctx.Err()
+		case errors.As(err, &api_err) && !api_err.Retryable():
+			log.Println("stopped polling", url, err)
+			return err
+		case err != nil:
+			log.Println("failed polling", url, err)
+			interval = options.MaxInterval
+		default:
+			// the listing is newest first
+			var fresh []RedditItem
+			for _, item := range listing.Items {
+				if seen.add(item.Name) {
+					fresh = append(fresh, item)
+				}
+			}
+			if first && options.SkipExisting {
+				fresh = nil
+			}
+			first = false
+			for i := len(fresh) - 1; i >= 0; i-- {
+				select {
+				case items <- fresh[i]:
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			}
+			interval = nextPollInterval(interval, len(fresh), len(listing.Items), options)
+		}
+
+		timer := time.NewTimer(interval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// a page with nothing but new items may have missed some so the next poll comes as soon as allowed
+func nextPollInterval(interval time.Duration, fresh, total int, options StreamOptions) time.Duration {
+	switch {
+	case fresh > 0 && fresh == total:
+		return options.MinInterval
+	case fresh > 0:
+		return max(interval/2, options.MinInterval)
+	default:
+		return min(interval*2, options.MaxInterval)
+	}
+}
+
+// remembers the last limit fullnames that were added
+type seenSet struct {
+	names map[string]bool
+	order []string // ring buffer of the names in the order they were added
+	next  int
+}
+
+func newSeenSet(limit int) *seenSet {
+	return &seenSet{
+		names: make(map[string]bool, limit),
+		order: make([]string, 0, limit),
+	}
+}
+
+// false if the name is already in the set
+func (seen *seenSet) add(name string) bool {
+	if seen.names[name] {
+		return false
+	}
+	if len(seen.order) < cap(seen.order) {
+		seen.order = append(seen.order, name)
+	} else {
+		delete(seen.names, seen.order[seen.next])
+		seen.order[seen.next] = name
+		seen.next = (seen.next + 1) % len(seen.order)
+	}
+	seen.names[name] = true
+	return true
+}
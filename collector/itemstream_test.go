@@ -0,0 +1,49 @@
+package sdk
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// a page that does not change must be sent once no matter how small SeenLimit is
+func TestStreamPostsSendsEachPostOnce(t *testing.T) {
+	var children []string
+	for i := 0; i < MAX_PAGE_LIMIT; i++ {
+		children = append(children, fmt.Sprintf(`{"kind": "t3", "data": {"name": "t3_%d"}}`, i))
+	}
+	page := `{"data": {"children": [` + strings.Join(children, ",") + `]}}`
+	var polls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		polls.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(page))
+	}))
+	defer server.Close()
+	client := NewAuthenticatedRedditClient(&RedditUser{UserId: "stream-test", AccessToken: "token"}, RedditClientConfig{})
+	client.http_client.SetBaseURL(server.URL)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stream := client.StreamPosts(ctx, StreamOptions{MinInterval: time.Millisecond, MaxInterval: time.Millisecond, SeenLimit: 50}, "golang")
+	received := 0
+	for polls.Load() < 4 {
+		select {
+		case <-stream.Items:
+			received += 1
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	cancel()
+	for range stream.Items {
+		received += 1
+	}
+	if received != MAX_PAGE_LIMIT {
+		t.Errorf("expected %d posts, got %d", MAX_PAGE_LIMIT, received)
+	}
+}
@@ -0,0 +1,76 @@
+package sdk
+
+// walks through the pages of a listing endpoint by following the `after` cursor
+// fetch is any of the listing methods bound to its arguments e.g.
+//
+//	NewListingIterator(client.Subreddits, ListingOptions{Limit: 100}, 500)
+//	NewListingIterator(func(options ListingOptions) (Listing, error) { return client.Posts(sr, PostsOptions{Sort: NEW, ListingOptions: options}) }, ListingOptions{}, 50)
+type ListingIterator struct {
+	fetch     func(options ListingOptions) (Listing, error)
+	options   ListingOptions
+	max_items int // stop after this many items. 0 or less means walk until reddit runs out of pages
+	collected int
+	done      bool
+	err       error
+}
+
+func NewListingIterator(fetch func(options ListingOptions) (Listing, error), options ListingOptions, max_items int) *ListingIterator {
+	return &ListingIterator{
+		fetch:     fetch,
+		options:   options,
+		max_items: max_items,
+	}
+}
+
+// returns the next page of items. the second return value is false when there is nothing left to read
+// either because reddit has no more pages, the max_items cap has been reached or a request failed. check Err() for the latter
+func (it *ListingIterator) Next() ([]RedditItem, bool) {
+	if it.done {
+		return nil, false
+	}
+
+	// don't ask for more than what is remaining under the cap
+	if it.max_items > 0 {
+		remaining := it.max_items - it.collected
+		if it.options.Limit <= 0 || it.options.Limit > remaining {
+			it.options.Limit = remaining
+		}
+	}
+
+	page, err := it.fetch(it.options)
+	if err != nil {
+		it.err = err
+		it.done = true
+		return nil, false
+	}
+
+	items := page.Items
+	if it.max_items > 0 && it.collected+len(items) > it.max_items {
+		items = items[:it.max_items-it.collected]
+	}
+	it.collected += len(items)
+
+	// reddit sends back an empty after cursor on the last page
+	if page.After == "" || len(page.Items) == 0 || (it.max_items > 0 && it.collected >= it.max_items) {
+		it.done = true
+	}
+	it.options.After = page.After
+	it.options.Before = ""
+	it.options.Count = it.collected
+
+	return items, len(items) > 0
+}
+
+// reads all the remaining pages and returns the items collected so far even if a request fails midway
+func (it *ListingIterator) All() ([]RedditItem, error) {
+	var items []RedditItem
+	for page, ok := it.Next(); ok; page, ok = it.Next() {
+		items = append(items, page...)
+	}
+	return items, it.err
+}
+
+// error from the last failed request if any
+func (it *ListingIterator) Err() error {
+	return it.err
+}
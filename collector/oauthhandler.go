@@ -0,0 +1,132 @@
+package sdk
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"html"
+	"log"
+	"net/http"
+)
+
+const (
+	// holds the nonce of the state between Authorize and Callback so that only the browser that started the flow can finish it
+	OAUTH_NONCE_COOKIE = "reddit_oauth_nonce"
+)
+
+// net/http handlers for linking reddit accounts to the collector
+//
+//	mux := http.NewServeMux()
+//	NewOauthHandler(collector, session_user_id).Register(mux, "/reddit")
+//
+// the config's RedirectUri has to point to the callback i.e. https://<host>/reddit/callback
+type OauthHandler struct {
+	collector *RedditCollector
+	user_id   func(r *http.Request) (string, error)
+}
+
+// user_id gets the id of the signed in user from the app's own session e.g. its session cookie
+// it must never come from the request parameters. otherwise anyone could link their reddit account to someone else's id
+func NewOauthHandler(collector *RedditCollector, user_id func(r *http.Request) (string, error)) *OauthHandler {
+	return &OauthHandler{collector: collector, user_id: user_id}
+}
+
+// mounts the handlers at <prefix>/authorize, <prefix>/callback and <prefix>/status
+func (handler *OauthHandler) Register(mux *http.ServeMux, prefix string) {
+	mux.HandleFunc(prefix+"/authorize", handler.Authorize)
+	mux.HandleFunc(prefix+"/callback", handler.Callback)
+	mux.HandleFunc(prefix+"/status", handler.Status)
+}
+
+// GET
+// redirects the signed in user to reddit's consent page. reddit sends the user back to the callback afterwards
+func (handler *OauthHandler) Authorize(w http.ResponseWriter, r *http.Request) {
+	user_id, ok := handler.signedInUser(w, r)
+	if !ok {
+		return
+	}
+	client_config := handler.collector.config.RedditClientConfig
+	state_manager := client_config.stateManager()
+	state := state_manager.Generate(user_id)
+	http.SetCookie(w, &http.Cookie{
+		Name:     OAUTH_NONCE_COOKIE,
+		Value:    stateNonce(state),
+		Path:     "/",
+		MaxAge:   int(state_manager.ttl.Seconds()),
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		// reddit's redirect to the callback is a top level navigation from another site. strict would leave the cookie out
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, authorizationUrl(state, client_config), http.StatusFound)
+}
+
+// GET ?state=<state>&code=<code> or ?state=<state>&error=<error>
+// reddit redirects here after the user approves or denies access. on approval the account gets added to the collector
+func (handler *OauthHandler) Callback(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	client_config := handler.collector.config.RedditClientConfig
+
+	// a state that someone else got for the user id can't be finished from this browser
+	nonce, err := r.Cookie(OAUTH_NONCE_COOKIE)
+	http.SetCookie(w, &http.Cookie{Name: OAUTH_NONCE_COOKIE, Path: "/", MaxAge: -1, HttpOnly: true})
+	state_nonce := stateNonce(params.Get("state"))
+	if err != nil || state_nonce == "" || subtle.ConstantTimeCompare([]byte(nonce.Value), []byte(state_nonce)) != 1 {
+		http.Error(w, "Invalid or expired authorization request. Please start over.", http.StatusBadRequest)
+		return
+	}
+
+	if reddit_err := params.Get("error"); reddit_err != "" {
+		// still burn the state so that it can't be replayed with a code
+		user_id, err := client_config.stateManager().Verify(params.Get("state"))
+		if err != nil {
+			http.Error(w, "Invalid or expired authorization request. Please start over.", http.StatusBadRequest)
+		} else if reddit_err == "access_denied" {
+			http.Error(w, "Access to Reddit was denied. Nothing will be collected for this account.", http.StatusForbidden)
+		} else {
+			log.Println("Reddit authorization failed for", user_id, reddit_err)
+			http.Error(w, "Reddit authorization failed: "+reddit_err, http.StatusBadRequest)
+		}
+		return
+	}
+
+	client, err := NewOauthRedditClientContext(r.Context(), params.Get("state"), params.Get("code"), client_config)
+	if errors.Is(err, ErrInvalidOauthState) {
+		http.Error(w, "Invalid or expired authorization request. Please start over.", http.StatusBadRequest)
+		return
+	} else if err != nil {
+		http.Error(w, "Some authorization issue", http.StatusUnauthorized)
+		return
+	}
+	if err := handler.collector.AddCollectionAccount(*client.User); err != nil {
+		log.Println("failed storing account", client.User.UserId, err)
+		http.Error(w, "Failed saving the account", http.StatusInternalServerError)
+		return
+	}
+	fmt.Fprintf(w, "Authentication Succeeded for %s. Feel Free to Close the Window.", html.EscapeString(client.User.UserId))
+}
+
+// GET
+// 200 if the signed in user has a linked account or else 404. send the user to Authorize to link one
+func (handler *OauthHandler) Status(w http.ResponseWriter, r *http.Request) {
+	user_id, ok := handler.signedInUser(w, r)
+	if !ok {
+		return
+	}
+	if handler.collector.GetCollectionAccount(user_id) == nil {
+		http.Error(w, "Not linked", http.StatusNotFound)
+		return
+	}
+	fmt.Fprint(w, "Linked")
+}
+
+// responds with 401 if there is no signed in user
+// without a user id every linked account would end up under the same one and replace the one before it
+func (handler *OauthHandler) signedInUser(w http.ResponseWriter, r *http.Request) (string, bool) {
+	user_id, err := handler.user_id(r)
+	if err != nil || user_id == "" {
+		http.Error(w, "Sign in first", http.StatusUnauthorized)
+		return "", false
+	}
+	return user_id, true
+}
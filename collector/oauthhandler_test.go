@@ -0,0 +1,94 @@
+package sdk
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// the user id comes from the app's session. a query parameter must not be able to pick it
+func TestOauthHandlerNeedsSignedInUser(t *testing.T) {
+	session := func(r *http.Request) (string, error) {
+		if cookie, err := r.Cookie("session"); err == nil {
+			return cookie.Value, nil
+		}
+		return "", errors.New("not signed in")
+	}
+	handler := NewOauthHandler(NewCollector(CollectorConfig{}), session)
+
+	for _, endpoint := range []func(http.ResponseWriter, *http.Request){handler.Authorize, handler.Status} {
+		recorder := httptest.NewRecorder()
+		endpoint(recorder, httptest.NewRequest(http.MethodGet, "/?userid=victim", nil))
+		if recorder.Code != http.StatusUnauthorized {
+			t.Errorf("expected %d without a session, got %d", http.StatusUnauthorized, recorder.Code)
+		}
+	}
+
+	request := httptest.NewRequest(http.MethodGet, "/authorize", nil)
+	request.AddCookie(&http.Cookie{Name: "session", Value: "someone"})
+	recorder := httptest.NewRecorder()
+	handler.Authorize(recorder, request)
+	if recorder.Code != http.StatusFound {
+		t.Errorf("expected %d with a session, got %d", http.StatusFound, recorder.Code)
+	}
+
+	request = httptest.NewRequest(http.MethodGet, "/status", nil)
+	request.AddCookie(&http.Cookie{Name: "session", Value: "someone"})
+	recorder = httptest.NewRecorder()
+	handler.Status(recorder, request)
+	if recorder.Code != http.StatusNotFound {
+		t.Errorf("expected %d for an account that is not linked, got %d", http.StatusNotFound, recorder.Code)
+	}
+	if strings.Contains(recorder.Body.String(), "state") {
+		t.Errorf("status must not hand out an authorization url: %s", recorder.Body.String())
+	}
+}
+
+// a state minted for the user in one browser can't be finished in another
+func TestCallbackNeedsNonceCookie(t *testing.T) {
+	handler := NewOauthHandler(NewCollector(CollectorConfig{}), func(*http.Request) (string, error) { return "victim", nil })
+	recorder := httptest.NewRecorder()
+	handler.Authorize(recorder, httptest.NewRequest(http.MethodGet, "/authorize", nil))
+	location, err := url.Parse(recorder.Header().Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	state := location.Query().Get("state")
+	var nonce *http.Cookie
+	for _, cookie := range recorder.Result().Cookies() {
+		if cookie.Name == OAUTH_NONCE_COOKIE {
+			nonce = cookie
+		}
+	}
+	if nonce == nil || !nonce.HttpOnly || nonce.Value != stateNonce(state) {
+		t.Fatalf("expected an HttpOnly cookie with the nonce of the state, got %+v", nonce)
+	}
+
+	for name, cookie := range map[string]*http.Cookie{
+		"no cookie":    nil,
+		"other nonce":  {Name: OAUTH_NONCE_COOKIE, Value: "other"},
+		"empty cookie": {Name: OAUTH_NONCE_COOKIE, Value: ""},
+	} {
+		request := httptest.NewRequest(http.MethodGet, "/callback?error=access_denied&state="+url.QueryEscape(state), nil)
+		if cookie != nil {
+			request.AddCookie(cookie)
+		}
+		recorder := httptest.NewRecorder()
+		handler.Callback(recorder, request)
+		if recorder.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected %d, got %d", name, http.StatusBadRequest, recorder.Code)
+		}
+	}
+
+	// the browser that started the flow gets through to the state check
+	request := httptest.NewRequest(http.MethodGet, "/callback?error=access_denied&state="+url.QueryEscape(state), nil)
+	request.AddCookie(&http.Cookie{Name: OAUTH_NONCE_COOKIE, Value: nonce.Value})
+	recorder = httptest.NewRecorder()
+	handler.Callback(recorder, request)
+	if recorder.Code != http.StatusForbidden {
+		t.Errorf("expected %d for a denied consent, got %d", http.StatusForbidden, recorder.Code)
+	}
+}
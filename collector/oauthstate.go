@@ -0,0 +1,120 @@
+package sdk
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// how long a user has to go through reddit's consent page
+	OAUTH_STATE_TTL = 10 * time.Minute
+)
+
+var ErrInvalidOauthState = errors.New("invalid, expired or already used oauth state")
+
+// generates and verifies the state parameter of reddit's consent page
+// the state is <base64 user id>.<nonce>.<expiry>.<hmac> so that the callback can trust the user id it gets back. each state can be verified only once
+// a valid state alone doesn't prove who started the flow. OauthHandler also checks the nonce against a cookie of the browser it was issued to
+// used nonces are tracked in memory. instances behind a load balancer need sticky sessions for the single-use check to hold
+type OauthStateManager struct {
+	secret []byte
+	ttl    time.Duration
+
+	lock sync.Mutex
+	used map[string]int64 // nonce -> expiry of the state it came with
+}
+
+// a random secret is generated if secret is empty. states then don't survive a restart but they expire in minutes anyway
+func NewOauthStateManager(secret []byte, ttl time.Duration) *OauthStateManager {
+	if len(secret) == 0 {
+		secret = make([]byte, 32)
+		rand.Read(secret)
+	}
+	if ttl <= 0 {
+		ttl = OAUTH_STATE_TTL
+	}
+	return &OauthStateManager{
+		secret: secret,
+		ttl:    ttl,
+		used:   make(map[string]int64),
+	}
+}
+
+var default_state_manager = NewOauthStateManager(nil, OAUTH_STATE_TTL)
+
+func (manager *OauthStateManager) Generate(user_id string) string {
+	nonce := make([]byte, 16)
+	rand.Read(nonce)
+	payload := strings.Join([]string{
+		base64.RawURLEncoding.EncodeToString([]byte(user_id)),
+		base64.RawURLEncoding.EncodeToString(nonce),
+		strconv.FormatInt(time.Now().Add(manager.ttl).Unix(), 10),
+	}, ".")
+	return payload + "." + manager.signature(payload)
+}
+
+// returns the user id the state was generated for. fails if the state was tampered with, has expired or was already used
+func (manager *OauthStateManager) Verify(state string) (string, error) {
+	parts := strings.Split(state, ".")
+	if len(parts) != 4 {
+		return "", ErrInvalidOauthState
+	}
+	payload := strings.Join(parts[:3], ".")
+	if !hmac.Equal([]byte(parts[3]), []byte(manager.signature(payload))) {
+		return "", ErrInvalidOauthState
+	}
+
+	expiry, err := strconv.ParseInt(parts[2], 10, 64)
+	now := time.Now().Unix()
+	if err != nil || now > expiry {
+		return "", ErrInvalidOauthState
+	}
+	user_id, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return "", ErrInvalidOauthState
+	}
+
+	manager.lock.Lock()
+	defer manager.lock.Unlock()
+	// forget the nonces of expired states. those fail the expiry check anyway
+	for nonce, nonce_expiry := range manager.used {
+		if now > nonce_expiry {
+			delete(manager.used, nonce)
+		}
+	}
+	if _, ok := manager.used[parts[1]]; ok {
+		return "", ErrInvalidOauthState
+	}
+	manager.used[parts[1]] = expiry
+	return string(user_id), nil
+}
+
+// the nonce part of the state. empty if the state is malformed
+func stateNonce(state string) string {
+	parts := strings.Split(state, ".")
+	if len(parts) != 4 {
+		return ""
+	}
+	return parts[1]
+}
+
+func (manager *OauthStateManager) signature(payload string) string {
+	mac := hmac.New(sha256.New, manager.secret)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// the manager of the config or the package wide default one
+func (client_config *RedditClientConfig) stateManager() *OauthStateManager {
+	if client_config.StateManager != nil {
+		return client_config.StateManager
+	}
+	return default_state_manager
+}
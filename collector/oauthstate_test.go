@@ -0,0 +1,53 @@
+package sdk
+
+import (
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOauthStateRoundTrip(t *testing.T) {
+	manager := NewOauthStateManager([]byte("secret"), time.Minute)
+	user_id, err := manager.Verify(manager.Generate("someone"))
+	if err != nil || user_id != "someone" {
+		t.Fatalf("expected someone, got %q and %v", user_id, err)
+	}
+}
+
+func TestOauthStateRejected(t *testing.T) {
+	manager := NewOauthStateManager([]byte("secret"), time.Minute)
+	replace := func(state string, part int, value string) string {
+		parts := strings.Split(state, ".")
+		parts[part] = value
+		return strings.Join(parts, ".")
+	}
+	expired := func() string {
+		payload := strings.Join([]string{
+			base64.RawURLEncoding.EncodeToString([]byte("someone")),
+			"bm9uY2U",
+			strconv.FormatInt(time.Now().Add(-time.Second).Unix(), 10),
+		}, ".")
+		return payload + "." + manager.signature(payload)
+	}
+	replayed := manager.Generate("someone")
+	if _, err := manager.Verify(replayed); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, state := range map[string]string{
+		"tampered payload":   replace(manager.Generate("someone"), 0, base64.RawURLEncoding.EncodeToString([]byte("victim"))),
+		"tampered signature": replace(manager.Generate("someone"), 3, manager.signature("something else")),
+		"expired":            expired(),
+		"replayed":           replayed,
+		"another key":        NewOauthStateManager([]byte("another secret"), time.Minute).Generate("someone"),
+		"malformed":          "not.a.state",
+		"empty":              "",
+	} {
+		if user_id, err := manager.Verify(state); !errors.Is(err, ErrInvalidOauthState) {
+			t.Errorf("%s: expected ErrInvalidOauthState, got %q and %v", name, user_id, err)
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package sdk
+
+import (
+	"context"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+)
+
+const (
+	MAX_REQUEST_RETRIES     = 3                // number of times a request is retried on 429 and 5xx
+	RETRY_WAIT_TIME         = 1 * time.Second  // base of the exponential backoff between retries
+	RETRY_MAX_WAIT_TIME     = 60 * time.Second // cap on the wait between retries
+	MIN_RATELIMIT_REMAINING = 1                // stop sending requests when the remaining quota drops below this until the window resets
+)
+
+// snapshot of the reddit api quota for an oauth identity as reported by the X-Ratelimit-* headers
+type RateLimitState struct {
+	Remaining float64   // requests remaining in the current window
+	Used      int       // requests used in the current window
+	Reset     time.Time // when the current window ends and the quota resets
+	Updated   time.Time // when reddit last reported the state. zero if no response has been received yet
+}
+
+// tracks the quota of one oauth identity. shared by all clients of the same identity
+type rateLimiter struct {
+	lock  sync.Mutex
+	state RateLimitState
+}
+
+var rate_limiters = struct {
+	lock  sync.Mutex
+	items map[string]*rateLimiter
+}{items: make(map[string]*rateLimiter)}
+
+// returns the limiter for the given identity creating one if needed
+func getRateLimiter(identity string) *rateLimiter {
+	rate_limiters.lock.Lock()
+	defer rate_limiters.lock.Unlock()
+	limiter, ok := rate_limiters.items[identity]
+	if !ok {
+		limiter = &rateLimiter{}
+		rate_limiters.items[identity] = limiter
+	}
+	return limiter
+}
+
+// returns the last known quota for the identity of this client
+func (client *RedditClient) RateLimit() RateLimitState {
+	return client.limiter.State()
+}
+
+func (limiter *rateLimiter) State() RateLimitState {
+	limiter.lock.Lock()
+	defer limiter.lock.Unlock()
+	return limiter.state
+}
+
+// blocks until the quota allows one more request and then reserves it
+// returns early with the context's error if it gets cancelled while waiting
+func (limiter *rateLimiter) wait(ctx context.Context) error {
+	limiter.lock.Lock()
+	var delay time.Duration
+	if !limiter.state.Updated.IsZero() && limiter.state.Remaining < MIN_RATELIMIT_REMAINING {
+		delay = time.Until(limiter.state.Reset)
+	}
+	// reserve one request so that concurrent callers don't all see the same remaining quota
+	limiter.state.Remaining -= 1
+	limiter.lock.Unlock()
+
+	if delay <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// reads the X-Ratelimit-* headers from a response
+func (limiter *rateLimiter) update(header http.Header) {
+	remaining, err_remaining := strconv.ParseFloat(header.Get("X-Ratelimit-Remaining"), 64)
+	reset, err_reset := strconv.ParseFloat(header.Get("X-Ratelimit-Reset"), 64)
+	if err_remaining != nil || err_reset != nil {
+		// not every endpoint sends these
+		return
+	}
+	used, _ := strconv.Atoi(header.Get("X-Ratelimit-Used"))
+
+	limiter.lock.Lock()
+	defer limiter.lock.Unlock()
+	limiter.state = RateLimitState{
+		Remaining: remaining,
+		Used:      used,
+		Reset:     time.Now().Add(time.Duration(reset * float64(time.Second))),
+		Updated:   time.Now(),
+	}
+}
+
+// retry 429 and, for reads only, 5xx and transport failures. everything else is either a success or a failure that won't go away by retrying
+// a write that got a 5xx or lost its connection may have gone through anyway and retrying it could post twice. a 429 never gets that far
+// resty only retries transport failures on its own when there are no retry conditions so they have to be handled here
+func isRetryableResponse(resp *resty.Response, err error) bool {
+	if resp == nil {
+		// the request never went out e.g. the context got cancelled while waiting for the quota
+		return false
+	}
+	is_read := resp.Request.Method == resty.MethodGet
+	if err != nil {
+		return is_read
+	}
+	return resp.StatusCode() == http.StatusTooManyRequests ||
+		(is_read && resp.StatusCode() >= http.StatusInternalServerError)
+}
+
+// on 429 wait until the quota resets (with a bit of jitter so that concurrent clients don't fire together)
+// for everything else returning 0 makes resty fall back to its exponential backoff with jitter
+func (limiter *rateLimiter) retryAfter(_ *resty.Client, resp *resty.Response) (time.Duration, error) {
+	if resp.StatusCode() != http.StatusTooManyRequests {
+		return 0, nil
+	}
+	delay := time.Until(limiter.State().Reset)
+	if seconds, err := strconv.Atoi(resp.Header().Get("Retry-After")); err == nil {
+		delay = time.Duration(seconds) * time.Second
+	}
+	if delay <= 0 {
+		return 0, nil
+	}
+	return delay + time.Duration(rand.Int63n(int64(RETRY_WAIT_TIME))), nil
+}
+
+// spaces out requests across all the clients it is shared with
+// reddit's quota is per account so this is only needed to stay polite when collecting for many accounts at once
+type RequestPacer struct {
+	lock     sync.Mutex
+	interval time.Duration
+	next     time.Time // earliest time the next request can go out
+}
+
+func NewRequestPacer(requests_per_second float64) *RequestPacer {
+	return &RequestPacer{interval: time.Duration(float64(time.Second) / requests_per_second)}
+}
+
+// blocks until it is this caller's turn. returns early with the context's error if it gets cancelled while waiting
+func (pacer *RequestPacer) wait(ctx context.Context) error {
+	if pacer == nil {
+		return ctx.Err()
+	}
+	pacer.lock.Lock()
+	now := time.Now()
+	if pacer.next.Before(now) {
+		pacer.next = now
+	}
+	delay := pacer.next.Sub(now)
+	pacer.next = pacer.next.Add(pacer.interval)
+	pacer.lock.Unlock()
+
+	if delay <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,62 @@
+package sdk
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+// a write that failed with a 5xx may have gone through already so it must not be sent again
+func TestWritesAreNotRetriedOn5xx(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	client := NewAuthenticatedRedditClient(&RedditUser{UserId: "writes-test", AccessToken: "token"}, RedditClientConfig{})
+	client.http_client.SetBaseURL(server.URL).SetRetryWaitTime(0).SetRetryMaxWaitTime(0)
+
+	if _, err := client.Reply(&RedditItem{Name: "t3_abc"}, "hello"); err == nil {
+		t.Fatal("expected the reply to fail")
+	}
+	if got := requests.Load(); got != 1 {
+		t.Errorf("expected 1 request for a write, got %d", got)
+	}
+
+	requests.Store(0)
+	if _, err := client.PostsById("t3_abc"); err == nil {
+		t.Fatal("expected the read to fail")
+	}
+	if got := requests.Load(); got != MAX_REQUEST_RETRIES+1 {
+		t.Errorf("expected %d requests for a read, got %d", MAX_REQUEST_RETRIES+1, got)
+	}
+}
+
+// connections that drop before a response are retried for reads
+func TestReadsAreRetriedOnTransportFailures(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 1 {
+			conn, _, _ := w.(http.Hijacker).Hijack()
+			conn.Close()
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"data": {"children": [{"kind": "t3", "data": {"name": "t3_abc"}}]}}`))
+	}))
+	defer server.Close()
+
+	client := NewAuthenticatedRedditClient(&RedditUser{UserId: "transport-test", AccessToken: "token"}, RedditClientConfig{})
+	client.http_client.SetBaseURL(server.URL).SetRetryWaitTime(0).SetRetryMaxWaitTime(0)
+
+	listing, err := client.PostsById("t3_abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(listing.Items) != 1 || requests.Load() != 2 {
+		t.Errorf("expected the post after a retry, got %d items in %d requests", len(listing.Items), requests.Load())
+	}
+}
@@ -3,9 +3,14 @@ package sdk
 import (
 	// "log"
 
+	"context"
 	"fmt"
 	"log"
+	"net/http"
 	"net/url"
+	"strconv"
+	"strings"
+	"sync"
 
 	"github.com/go-resty/resty/v2"
 )
@@ -13,12 +18,15 @@ import (
 const (
 	REDDIT_OAUTH_AUTHORIZE_URL = "https://www.reddit.com/api/v1/authorize"
 	REDDIT_OAUTH_URL           = "https://www.reddit.com/api/v1/access_token"
+	REDDIT_OAUTH_REVOKE_URL    = "https://www.reddit.com/api/v1/revoke_token"
 	REDDIT_DATA_URL            = "https://oauth.reddit.com"
 )
 
 // internal wrapper data structure to ease json marshalling and unmarshalling
 type listingData struct {
 	Data struct {
+		After    string `json:"after"`
+		Before   string `json:"before"`
 		Children []struct {
 			Kind string     `json:"kind"`
 			Data RedditItem `json:"data"`
@@ -26,6 +34,23 @@ type listingData struct {
 	} `json:"data"`
 }
 
+// paging parameters accepted by all reddit listing endpoints
+// After and Before are fullnames (Name) of items returned by a previous page. Leave both empty for the first page
+// Limit is the page size (reddit defaults to 25 and caps at 100). Count is the number of items already seen
+type ListingOptions struct {
+	After  string
+	Before string
+	Limit  int
+	Count  int
+}
+
+// one page of items returned by a listing endpoint along with the cursors to the adjacent pages
+type Listing struct {
+	Items  []RedditItem
+	After  string // fullname to pass as ListingOptions.After to get the next page. Empty when there are no more pages
+	Before string // fullname to pass as ListingOptions.Before to get the previous page
+}
+
 // represents Subreddit, Posts, Comments
 type RedditItem struct {
 	Kind          string // Subreddit, Post or Comment. This is not directly serialized
@@ -61,6 +86,11 @@ type RedditItem struct {
 	UserIsSubscriber  bool `json:"user_is_subscriber"`
 	UserIsModerator   bool `json:"user_is_moderator"`
 	UserIsContributor bool `json:"user_is_contributor"`
+
+	// collecting user specific info for posts and comments
+	Likes  *bool `json:"likes"` // true if the user upvoted, false if downvoted, nil if neither
+	Saved  bool  `json:"saved"`
+	Hidden bool  `json:"hidden"`
 }
 
 // the json tags are there to accommodate serialization directly from reddit api
@@ -70,30 +100,60 @@ type RedditUser struct {
 	Password     string `json:"ignore_password,omitempty"`
 	AccessToken  string `json:"ignore_access_token,omitempty"`
 	RefreshToken string `json:"ignore_refresh_token,omitempty"`
+	TokenExpiry  int64  `json:"ignore_token_expiry,omitempty"` // unix time (seconds) when AccessToken expires. 0 means unknown
+	AppOnly      bool   `json:"ignore_app_only,omitempty"`     // authenticates as the app itself instead of a reddit account. can only read public data
 }
 
+const (
+	APP_ONLY_USERID = "__APP_ONLY__"
+)
+
 const (
 	SUBREDDIT = "subreddit"
 	POST      = "post"
 	COMMENT   = "comment"
 )
 
+// sort orders for listings and search results
+const (
+	HOT           = "hot"
+	TOP           = "top"
+	BEST          = "best"
+	NEW           = "new"
+	RISING        = "rising"
+	CONTROVERSIAL = "controversial"
+	RELEVANCE     = "relevance"
+	COMMENTS      = "comments"
+)
+
+// time windows for TOP and CONTROVERSIAL sorted listings and search results
 const (
-	HOT  = "hot"
-	TOP  = "top"
-	BEST = "best"
+	HOUR     = "hour"
+	DAY      = "day"
+	WEEK     = "week"
+	MONTH    = "month"
+	YEAR     = "year"
+	ALL_TIME = "all"
 )
 
 type RedditClient struct {
 	http_client *resty.Client
 	User        *RedditUser
 	config      RedditClientConfig
+	// guards the token fields of User and serializes refreshes
+	token_lock       sync.Mutex
+	on_token_refresh func(user RedditUser)
+	// quota of the oauth identity. shared with other clients of the same user
+	limiter *rateLimiter
 }
 
 type RedditAuthenticationResult struct {
 	AccessToken    string `json:"access_token"`
 	RefreshToken   string `json:"refresh_token"`
+	ExpiresIn      int64  `json:"expires_in"` // number of seconds the access token is valid for
+	Scope          string `json:"scope"`
 	FailureMessage string `json:"message"`
+	StatusCode     int    `json:"-"` // http status code of a failed token request. 0 if no request was made
 }
 
 func (res RedditAuthenticationResult) Error() string {
@@ -101,171 +161,337 @@ func (res RedditAuthenticationResult) Error() string {
 }
 
 func NewRedditClient(user *RedditUser, client_config RedditClientConfig) (*RedditClient, error) {
-	if user.RefreshToken != "" {
-		// log.Println("OAUTH with refresh_token")
-		auth_grant := map[string]string{
-			"grant_type":    "refresh_token",
-			"refresh_token": user.RefreshToken,
-		}
-		return authenticateRedditClient(user.UserId, auth_grant, client_config)
-	} else if user.Password != "" {
-		// log.Println("OAUTH with password")
-		auth_grant := map[string]string{
-			"grant_type": "password",
-			"username":   user.Username,
-			"password":   user.Password,
-			"scope":      client_config.Scope,
-		}
-		return authenticateRedditClient(user.UserId, auth_grant, client_config)
+	return NewRedditClientContext(context.Background(), user, client_config)
+}
+
+func NewRedditClientContext(ctx context.Context, user *RedditUser, client_config RedditClientConfig) (*RedditClient, error) {
+	if user.AppOnly || user.RefreshToken != "" || user.Password != "" {
+		return authenticateRedditClient(ctx, *user, user.authGrant(client_config), client_config)
 	} else if user.AccessToken != "" {
 		// log.Println("OAUTH with auth_otken")
 		return NewAuthenticatedRedditClient(user, client_config), nil
 	} else {
-		return nil, &RedditAuthenticationResult{FailureMessage: "Insufficient Input Parameters. Needs either RefreshToken, Username+Password or existing AuthToken."}
+		return nil, &RedditAuthenticationResult{FailureMessage: "Insufficient Input Parameters. Needs either AppOnly, RefreshToken, Username+Password or existing AuthToken."}
 	}
 }
 
-func NewOauthRedditClient(user_id, code string, client_config RedditClientConfig) (*RedditClient, error) {
+// creates a client that authenticates as the app itself without a reddit account
+// uses the client_credentials grant if the config has an AppSecret, or else the installed_client grant with the config's DeviceId
+func NewAppOnlyRedditClient(client_config RedditClientConfig) (*RedditClient, error) {
+	return NewAppOnlyRedditClientContext(context.Background(), client_config)
+}
+
+func NewAppOnlyRedditClientContext(ctx context.Context, client_config RedditClientConfig) (*RedditClient, error) {
+	return NewRedditClientContext(ctx, &RedditUser{UserId: APP_ONLY_USERID, AppOnly: true}, client_config)
+}
+
+// exchanges the code reddit sent to the redirect uri for tokens
+// state is the one reddit sent along with the code. the user id it was generated for becomes client.User.UserId
+func NewOauthRedditClient(state, code string, client_config RedditClientConfig) (*RedditClient, error) {
+	return NewOauthRedditClientContext(context.Background(), state, code, client_config)
+}
+
+func NewOauthRedditClientContext(ctx context.Context, state, code string, client_config RedditClientConfig) (*RedditClient, error) {
+	user_id, err := client_config.stateManager().Verify(state)
+	if err != nil {
+		return nil, err
+	}
 	auth_grant := map[string]string{
 		"grant_type":   "authorization_code",
 		"code":         code,
 		"redirect_uri": client_config.RedirectUri,
 	}
-	return authenticateRedditClient(user_id, auth_grant, client_config)
+	return authenticateRedditClient(ctx, RedditUser{UserId: user_id}, auth_grant, client_config)
 }
 
 func NewAuthenticatedRedditClient(user *RedditUser, client_config RedditClientConfig) *RedditClient {
-	return &RedditClient{
-		User: user,
-		http_client: resty.New().
-			SetTimeout(MAX_WAIT_TIME).
-			SetBaseURL(REDDIT_DATA_URL).
-			SetHeader("User-Agent", client_config.AppName).
-			SetAuthToken(user.AccessToken),
-		config: client_config,
-	}
-}
-
-func authenticateRedditClient(user_id string, auth_grant map[string]string, client_config RedditClientConfig) (*RedditClient, error) {
-	var oauth_result RedditAuthenticationResult
-	resty.New().R().
-		SetBasicAuth(client_config.AppId, client_config.AppSecret).
-		SetHeader("User-Agent", client_config.RedirectUri).
-		SetHeader("Content-Type", URL_ENCODED_BODY).
-		SetFormData(auth_grant).
-		SetResult(&oauth_result).
-		SetError(&oauth_result).
-		Post(REDDIT_OAUTH_URL)
-
-	if oauth_result.AccessToken == "" {
+	client := &RedditClient{
+		User:    user,
+		config:  client_config,
+		limiter: getRateLimiter(user.identity()),
+	}
+	client.http_client = resty.New().
+		SetTimeout(MAX_WAIT_TIME).
+		SetBaseURL(REDDIT_DATA_URL).
+		SetHeader("User-Agent", client_config.AppName).
+		// hold off until the quota allows another request
+		OnBeforeRequest(func(_ *resty.Client, req *resty.Request) error {
+			if err := client_config.RequestPacer.wait(req.Context()); err != nil {
+				return err
+			}
+			return client.limiter.wait(req.Context())
+		}).
+		// the token is set per request so that a refreshed token gets picked up by every subsequent call
+		OnBeforeRequest(func(_ *resty.Client, req *resty.Request) error {
+			return client.authorizeRequest(req)
+		}).
+		OnAfterResponse(func(_ *resty.Client, resp *resty.Response) error {
+			client.limiter.update(resp.Header())
+			return nil
+		}).
+		SetRetryCount(MAX_REQUEST_RETRIES).
+		SetRetryWaitTime(RETRY_WAIT_TIME).
+		SetRetryMaxWaitTime(RETRY_MAX_WAIT_TIME).
+		SetRetryAfter(client.limiter.retryAfter).
+		// reddit returns 401 when the token has expired or got revoked. refresh it and try once more
+		AddRetryCondition(func(resp *resty.Response, _ error) bool {
+			return resp != nil &&
+				resp.StatusCode() == http.StatusUnauthorized &&
+				resp.Request.Attempt == 1 &&
+				client.refreshToken(resp.Request.Context(), resp.Request.Token) == nil
+		}).
+		AddRetryCondition(isRetryableResponse)
+	return client
+}
+
+func authenticateRedditClient(ctx context.Context, user RedditUser, auth_grant map[string]string, client_config RedditClientConfig) (*RedditClient, error) {
+	oauth_result, err := requestToken(ctx, auth_grant, client_config)
+	if err != nil {
 		log.Println("Authentication Failed")
-		return nil, &oauth_result
+		return nil, err
 	}
 
-	log.Println("Authentication Succeeded for", user_id)
-	client := NewAuthenticatedRedditClient(&RedditUser{UserId: user_id, AccessToken: oauth_result.AccessToken, RefreshToken: oauth_result.RefreshToken}, client_config)
+	log.Println("Authentication Succeeded for", user.UserId)
+	user.setTokens(oauth_result)
+	client := NewAuthenticatedRedditClient(&user, client_config)
 
-	// add username
-	if me_data, err := client.Me(); err == nil {
+	// add username. app-only tokens don't belong to any account
+	if user.AppOnly {
+		return client, nil
+	}
+	if me_data, err := client.MeContext(ctx); err == nil {
 		client.User.Username = me_data.Username
 	}
 	return client, nil
 }
 
 func (client *RedditClient) Me() (RedditUser, error) {
+	return client.MeContext(context.Background())
+}
+
+func (client *RedditClient) MeContext(ctx context.Context) (RedditUser, error) {
 	var me_data RedditUser
-	if _, err := client.http_client.R().
+	if err := client.responseError(client.http_client.R().
+		SetContext(ctx).
 		SetResult(&me_data).
-		Get("/api/v1/me"); err != nil {
+		Get("/api/v1/me")); err != nil {
 		return me_data, err
 	}
 	return me_data, nil
 }
 
 // gets subreddits that the user in the client has already subscribed to
-func (client *RedditClient) Subreddits() ([]RedditItem, error) {
-	var listing_data listingData
-	if _, err := client.http_client.R().
-		SetResult(&listing_data).
-		Get("/subreddits/mine/subscriber"); err != nil {
-		return nil, err
-	}
-	return listing_data.getItems(SUBREDDIT), nil
+func (client *RedditClient) Subreddits(options ListingOptions) (Listing, error) {
+	return client.SubredditsContext(context.Background(), options)
+}
+
+func (client *RedditClient) SubredditsContext(ctx context.Context, options ListingOptions) (Listing, error) {
+	return client.listing(ctx, "/subreddits/mine/subscriber", nil, SUBREDDIT, options)
 }
 
 // get subreddits based on a given
 // does not return unique list of items and may have duplicates
-// TODO: deal with paging
-func (client *RedditClient) SimilarSubreddits(subreddit *RedditItem) ([]RedditItem, error) {
-	var listing listingData
-	if _, err := client.http_client.R().
-		SetQueryParam("sr_fullnames", subreddit.Name).
-		SetResult(&listing).
-		Get("/api/similar_subreddits"); err != nil {
-		return nil, err
-	}
-	return listing.getItems(SUBREDDIT), nil
+func (client *RedditClient) SimilarSubreddits(subreddit *RedditItem, options ListingOptions) (Listing, error) {
+	return client.SimilarSubredditsContext(context.Background(), subreddit, options)
+}
+
+func (client *RedditClient) SimilarSubredditsContext(ctx context.Context, subreddit *RedditItem, options ListingOptions) (Listing, error) {
+	return client.listing(ctx, "/api/similar_subreddits", map[string]string{"sr_fullnames": subreddit.Name}, SUBREDDIT, options)
+}
+
+// gets the subreddits reddit shows to logged out users. this works with app-only clients
+func (client *RedditClient) DefaultSubreddits(options ListingOptions) (Listing, error) {
+	return client.DefaultSubredditsContext(context.Background(), options)
+}
+
+func (client *RedditClient) DefaultSubredditsContext(ctx context.Context, options ListingOptions) (Listing, error) {
+	return client.listing(ctx, "/subreddits/default", nil, SUBREDDIT, options)
 }
 
 // uses the query string to look for sub-reddits
-// min_users is used to filter for sub-reddits that has at least min_users number of users
-// TODO: deal with paging
-func (client *RedditClient) SubredditSearch(search_query string) ([]RedditItem, error) {
-	var listing listingData
-	if _, err := client.http_client.R().
-		SetQueryParam("q", search_query).
-		SetResult(&listing).
-		Get("/subreddits/search"); err != nil {
+func (client *RedditClient) SubredditSearch(search_query string, options ListingOptions) (Listing, error) {
+	return client.SubredditSearchContext(context.Background(), search_query, options)
+}
+
+func (client *RedditClient) SubredditSearchContext(ctx context.Context, search_query string, options ListingOptions) (Listing, error) {
+	return client.listing(ctx, "/subreddits/search", map[string]string{"q": search_query}, SUBREDDIT, options)
+}
+
+// gets the subreddits with the given display names e.g. "golang". names that don't exist are left out
+func (client *RedditClient) SubredditsByName(names ...string) (Listing, error) {
+	return client.SubredditsByNameContext(context.Background(), names...)
+}
+
+func (client *RedditClient) SubredditsByNameContext(ctx context.Context, names ...string) (Listing, error) {
+	return client.info(ctx, "sr_name", names, SUBREDDIT)
+}
+
+// gets the posts with the given fullnames e.g. "t3_1abcde". posts that don't exist are left out
+func (client *RedditClient) PostsById(fullnames ...string) (Listing, error) {
+	return client.PostsByIdContext(context.Background(), fullnames...)
+}
+
+func (client *RedditClient) PostsByIdContext(ctx context.Context, fullnames ...string) (Listing, error) {
+	return client.info(ctx, "id", fullnames, POST)
+}
+
+// looks up the items through /api/info in pages of MAX_PAGE_LIMIT which is the most it accepts in a single request
+func (client *RedditClient) info(ctx context.Context, param string, values []string, kind string) (Listing, error) {
+	var result Listing
+	for start := 0; start < len(values); start += MAX_PAGE_LIMIT {
+		page := values[start:min(start+MAX_PAGE_LIMIT, len(values))]
+		listing, err := client.listing(ctx, "/api/info", map[string]string{param: strings.Join(page, ",")}, kind, ListingOptions{Limit: len(page)})
+		if err != nil {
+			return result, err
+		}
+		result.Items = append(result.Items, listing.Items...)
+	}
+	return result, nil
+}
+
+// gets the display names of the subreddits in a multireddit
+// multipath is the path of the multireddit without the leading slash e.g. "user/someone/m/news"
+func (client *RedditClient) MultiredditSubreddits(multipath string) ([]string, error) {
+	return client.MultiredditSubredditsContext(context.Background(), multipath)
+}
+
+func (client *RedditClient) MultiredditSubredditsContext(ctx context.Context, multipath string) ([]string, error) {
+	var multi struct {
+		Data struct {
+			Subreddits []struct {
+				Name string `json:"name"`
+			} `json:"subreddits"`
+		} `json:"data"`
+	}
+	if err := client.responseError(client.http_client.R().
+		SetContext(ctx).
+		SetResult(&multi).
+		Get("/api/multi/" + strings.Trim(multipath, "/"))); err != nil {
 		return nil, err
 	}
-	return listing.getItems(SUBREDDIT), nil
+	names := make([]string, len(multi.Data.Subreddits))
+	for i, sr := range multi.Data.Subreddits {
+		names[i] = sr.Name
+	}
+	return names, nil
+}
+
+// gets the posts submitted by a user. options.Sort can be HOT, NEW, TOP or CONTROVERSIAL. reddit defaults to NEW
+func (client *RedditClient) UserPosts(username string, options PostsOptions) (Listing, error) {
+	return client.UserPostsContext(context.Background(), username, options)
+}
+
+func (client *RedditClient) UserPostsContext(ctx context.Context, username string, options PostsOptions) (Listing, error) {
+	params := make(map[string]string)
+	if options.Sort != "" {
+		params["sort"] = options.Sort
+	}
+	if options.Time != "" && (options.Sort == TOP || options.Sort == CONTROVERSIAL) {
+		params["t"] = options.Time
+	}
+	return client.listing(ctx, fmt.Sprintf("/user/%s/submitted", username), params, POST, options.ListingOptions)
+}
+
+// parameters for SearchPosts. empty fields are left to reddit's defaults
+type SearchOptions struct {
+	Query             string // search query. supports reddit's search syntax e.g. `title:golang author:someone`
+	Sort              string // RELEVANCE, HOT, TOP, NEW or COMMENTS
+	Time              string // HOUR, DAY, WEEK, MONTH, YEAR or ALL_TIME
+	RestrictSubreddit bool   // when searching within a subreddit, leave out results from other subreddits
+	Type              string // comma separated result types: link, sr, user. defaults to link i.e. posts
+	ListingOptions
+}
+
+// searches posts across reddit or within the subreddit if it is not nil
+func (client *RedditClient) SearchPosts(subreddit *RedditItem, options SearchOptions) (Listing, error) {
+	return client.SearchPostsContext(context.Background(), subreddit, options)
+}
+
+func (client *RedditClient) SearchPostsContext(ctx context.Context, subreddit *RedditItem, options SearchOptions) (Listing, error) {
+	url := "/search"
+	if subreddit != nil {
+		url = fmt.Sprintf("/%s/search", subreddit.DisplayNamePrefixed)
+	}
+	params := map[string]string{"q": options.Query}
+	if options.Sort != "" {
+		params["sort"] = options.Sort
+	}
+	if options.Time != "" {
+		params["t"] = options.Time
+	}
+	if options.RestrictSubreddit {
+		params["restrict_sr"] = "true"
+	}
+	if options.Type != "" {
+		params["type"] = options.Type
+		// other types were explicitly asked for. keep them
+		return client.listing(ctx, url, params, "*", options.ListingOptions)
+	}
+	return client.listing(ctx, url, params, POST, options.ListingOptions)
 }
 
-// gets posts: hot, best and top depending what is specified through post_type
+// parameters for Posts
+type PostsOptions struct {
+	Sort string // HOT, BEST, NEW, RISING, TOP or CONTROVERSIAL. defaults to HOT
+	Time string // HOUR, DAY, WEEK, MONTH, YEAR or ALL_TIME. only applies to TOP and CONTROVERSIAL. reddit defaults to DAY
+	ListingOptions
+}
+
+// gets posts: hot, best, new, rising, top and controversial depending what is specified through options.Sort
 // if sub_reddit display name is not specified it will pull from the overall list of posts instead of a specific subreddit
-// TODO: deal with paging
-func (client *RedditClient) Posts(subreddit *RedditItem, post_type string) ([]RedditItem, error) {
+func (client *RedditClient) Posts(subreddit *RedditItem, options PostsOptions) (Listing, error) {
+	return client.PostsContext(context.Background(), subreddit, options)
+}
+
+func (client *RedditClient) PostsContext(ctx context.Context, subreddit *RedditItem, options PostsOptions) (Listing, error) {
 	var url string
-	// if subreddit is NOT nil, pull in the post_type posts from the subreddit
+	// if subreddit is NOT nil, pull in the sorted posts from the subreddit
 	// or else pull in post from users top profile
 	if subreddit != nil {
 		url = fmt.Sprintf("/%s/", subreddit.DisplayNamePrefixed)
 	} else {
 		url = "/"
 	}
-	var listing listingData
-	if _, err := client.http_client.R().
-		SetResult(&listing).
-		Get(url + post_type); err != nil {
+	sort := options.Sort
+	if sort == "" {
+		sort = HOT
+	}
+	var params map[string]string
+	if options.Time != "" && (sort == TOP || sort == CONTROVERSIAL) {
+		params = map[string]string{"t": options.Time}
+	}
+	listing, err := client.listing(ctx, url+sort, params, POST, options.ListingOptions)
+	if err != nil {
 		log.Println("failed getting posts from", url)
-		return nil, err
 	}
-	return listing.getItems(POST), nil
+	return listing, err
 }
 
-// retrieves comments for a specific post
+// retrieves comments for a specific post including the nested replies, depth first
+// comments hidden behind "more" stubs are not loaded. use CommentTree for those
 func (client *RedditClient) RetrieveComments(post *RedditItem) ([]RedditItem, error) {
-	// this returns multiple listings
-	var listing []listingData
-	if _, err := client.http_client.R().
-		SetResult(&listing).
-		Get(fmt.Sprintf("/%s/comments/%s", post.SubredditPrefixed, post.Id)); err != nil {
+	return client.RetrieveCommentsContext(context.Background(), post)
+}
+
+func (client *RedditClient) RetrieveCommentsContext(ctx context.Context, post *RedditItem) ([]RedditItem, error) {
+	tree, err := client.CommentTreeContext(ctx, post, CommentTreeOptions{})
+	if err != nil {
 		log.Println("error pulling in comments", err)
 		return nil, err
 	}
-
-	var collection []RedditItem
-	for _, listing := range listing {
-		collection = append(collection, listing.getItems(COMMENT)...)
-	}
-	return collection, nil
+	return tree.Flatten(), nil
 }
 
+// url of reddit's consent page for the user. the state in it carries the user id signed by the config's StateManager
 func GetRedditAuthorizationUrl(user_id string, client_config RedditClientConfig) string {
+	return authorizationUrl(client_config.stateManager().Generate(user_id), client_config)
+}
+
+func authorizationUrl(state string, client_config RedditClientConfig) string {
 	params := url.Values{}
 	params.Add("client_id", client_config.AppId)
 	params.Add("response_type", "code")
-	params.Add("state", user_id)
+	params.Add("state", state)
 	params.Add("redirect_uri", client_config.RedirectUri)
 	params.Add("duration", "permanent")
 	params.Add("scope", client_config.Scope)
@@ -275,6 +501,49 @@ func GetRedditAuthorizationUrl(user_id string, client_config RedditClientConfig)
 
 // internal utility functions
 
+// key for the resources shared by all clients of the same reddit account
+func (user *RedditUser) identity() string {
+	if user.UserId != "" {
+		return user.UserId
+	}
+	return user.Username
+}
+
+// fetches one page from a listing endpoint and keeps only the items of the given kind
+func (client *RedditClient) listing(ctx context.Context, url string, params map[string]string, kind string, options ListingOptions) (Listing, error) {
+	var listing_data listingData
+	if err := client.responseError(client.http_client.R().
+		SetContext(ctx).
+		SetQueryParams(params).
+		SetQueryParams(options.queryParams()).
+		SetResult(&listing_data).
+		Get(url)); err != nil {
+		return Listing{}, err
+	}
+	return Listing{
+		Items:  listing_data.getItems(kind),
+		After:  listing_data.Data.After,
+		Before: listing_data.Data.Before,
+	}, nil
+}
+
+func (options ListingOptions) queryParams() map[string]string {
+	params := make(map[string]string)
+	if options.After != "" {
+		params["after"] = options.After
+	}
+	if options.Before != "" {
+		params["before"] = options.Before
+	}
+	if options.Limit > 0 {
+		params["limit"] = strconv.Itoa(options.Limit)
+	}
+	if options.Count > 0 {
+		params["count"] = strconv.Itoa(options.Count)
+	}
+	return params
+}
+
 func (listing_data *listingData) getItems(kind string) []RedditItem {
 	items := make([]RedditItem, len(listing_data.Data.Children))
 	var counter int = 0
@@ -283,7 +552,7 @@ func (listing_data *listingData) getItems(kind string) []RedditItem {
 		// check if the item is of the kind that is expected
 		if kind == "*" || kind == item_kind {
 			items[counter] = v.Data
-			items[counter].Kind = kind
+			items[counter].Kind = item_kind
 			counter += 1
 		}
 	}
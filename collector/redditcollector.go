@@ -1,10 +1,16 @@
 package sdk
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
 	"regexp"
+	"slices"
+	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
 
 	"github.com/PuerkitoBio/goquery"
 	ds "github.com/soumitsalman/beansack/sdk"
@@ -22,9 +28,11 @@ const (
 	_MASTER_COLLECTOR = "__DEFAULT_MASTER_COLLECTOR__"
 )
 
+// defaults of CollectionPolicy
 const (
 	MIN_SUBSCRIBER_LIMIT = 10000
 	MAX_POST_LIMIT       = 10
+	MAX_PAGE_LIMIT       = 100 // max number of items reddit returns in a single page
 )
 
 const (
@@ -43,104 +51,507 @@ const (
 type RedditCollector struct {
 	// initialize with default
 	config CollectorConfig
-	// these are the users whose data would be collected
-	// the config already provides a master account as a seed
-	authenticated_users []RedditUser
+	// config.BeanSink with the batching, retries and dead letters of config.SinkOptions
+	sink *RetryingSink
+	// the master account from the config. kept out of the account store so that its password never gets persisted
+	master_user *RedditUser
+	// guards the tokens of master_user. they get refreshed while other accounts are being collected
+	master_lock sync.Mutex
+	// these are the users whose data would be collected along with the master account
+	accounts AccountStore
+	// accounts advance the checkpoints of shared subreddits at the same time
+	checkpoint_lock sync.Mutex
+	// held for the whole of a collection run
+	run_lock sync.Mutex
 }
 
 func NewCollector(config CollectorConfig) *RedditCollector {
 	collector := RedditCollector{
-		config:              config,
-		authenticated_users: make([]RedditUser, 0, 10), // default holder
+		config:   config,
+		sink:     NewRetryingSink(config.BeanSink, config.SinkOptions),
+		accounts: config.AccountStore,
+	}
+	if collector.accounts == nil {
+		collector.accounts = NewMemoryAccountStore() // default holder
 	}
 	// if config has a master username defined add it
+	// without one the master can still read public subreddits with an app-only token
 	if len(config.MasterCollectorUsername) > 0 {
-		collector.AddCollectionAccount(RedditUser{
+		collector.master_user = &RedditUser{
 			UserId:   _MASTER_COLLECTOR,
 			Username: config.MasterCollectorUsername,
 			Password: config.MasterCollectorPassword,
-		})
+		}
+	} else if config.MasterCollectorAppOnly {
+		collector.master_user = &RedditUser{
+			UserId:  _MASTER_COLLECTOR,
+			AppOnly: true,
+		}
 	}
 	return &collector
 }
 
+// summary of a collection run
+type CollectionStats struct {
+	Users       int  // number of accounts whose collection got stored or streamed
+	Beans       int  // number of beans handed to the BeanSink or streamed
+	Engagements int  // number of engagements handed to EngagementFunc or streamed
+	Completed   bool // false if the run was cut short by the context. everything collected until then is still stored
+	// bean url -> ids of the accounts it was collected for. each bean is stored only once, along with the first of these accounts
+	CollectedFor map[string][]string
+}
+
 // COLLECTION RELATED FUNCTIONS
 func (collector *RedditCollector) Collect() {
-	for i := range collector.authenticated_users {
-		beans, _ := collector.collectUser(&collector.authenticated_users[i])
-		if len(beans) > 0 {
-			collector.config.store_func(beans)
-			// if user.UserId != _MASTER_COLLECTOR {
-			// 	beansack_client.StoreNewEngagements(engagements)
-			// }
-			log.Printf("Finished storing for u/%s\n", collector.authenticated_users[i].Username)
+	collector.CollectContext(context.Background())
+}
+
+// same as Collect but stops as soon as the context is cancelled
+// beans collected before the cancellation are stored and counted in the returned stats along with the context's error
+// failures of individual accounts or items don't stop the run. they are joined into the returned error
+// accounts are collected UserConcurrency at a time but their beans are always stored one account at a time in account order
+func (collector *RedditCollector) CollectContext(ctx context.Context) (CollectionStats, error) {
+	return collector.collect(ctx, nil, nil)
+}
+
+// runs a collection with the overrides of the schedule if there is one. the beans go to the stream if there is one or else to the BeanSink
+// runs of the same collector never overlap. a run waits for the one in progress to finish
+func (collector *RedditCollector) collect(ctx context.Context, stream *CollectionStream, schedule *CollectionSchedule) (CollectionStats, error) {
+	var stats CollectionStats
+	job, err := collector.newJob(schedule)
+	if err != nil {
+		return stats, err
+	}
+	collector.run_lock.Lock()
+	defer collector.run_lock.Unlock()
+	if stream == nil && collector.config.BeanSink == nil {
+		return stats, errors.New("CollectorConfig.BeanSink is required")
+	}
+	var errs []error
+	users, err := collector.collectionAccounts()
+	if err != nil {
+		// still collect for the master account
+		errs = append(errs, fmt.Errorf("loading accounts: %w", err))
+	}
+	// the targets get collected with the first account only. nothing is left for the others when subscriptions are skipped
+	if job.targets_only && len(users) > 1 {
+		users = users[:1]
+	}
+
+	type userResult struct {
+		userCollection
+		err error
+	}
+	// each item gets collected and stored once no matter how many accounts come across it
+	cache := newCollectionCache()
+	emit := stream.emitter()
+	// allocated before the workers start since the results are read while they are still being spawned
+	results := make([]chan userResult, len(users))
+	for i := range users {
+		results[i] = make(chan userResult, 1)
+	}
+	workers := make(chan struct{}, concurrency(collector.config.UserConcurrency))
+	go func() {
+		for i := range users {
+			workers <- struct{}{}
+			go func(i int) {
+				defer func() { <-workers }()
+				if ctx.Err() != nil {
+					results[i] <- userResult{}
+					return
+				}
+				job := job
+				if i > 0 {
+					job.targets = nil
+				}
+				if emit != nil {
+					emit(CollectionEvent{Kind: EVENT_USER_STARTED, UserId: users[i].UserId})
+				}
+				collection, err := collector.collectUser(ctx, &users[i], &job, cache, emit)
+				if emit != nil {
+					emit(CollectionEvent{Kind: EVENT_USER_FINISHED, UserId: users[i].UserId, Err: err})
+				}
+				results[i] <- userResult{collection, err}
+			}(i)
+		}
+	}()
+
+	// checkpoints only move once the sink has the beans. otherwise the next run would skip what never got stored
+	var checkpoints = make(map[string][]RedditItem)
+	var store_failed bool
+	for i := range users {
+		result := <-results[i]
+		if result.err != nil {
+			log.Printf("Collection failed for %s: %v\n", users[i].identity(), result.err)
+			errs = append(errs, fmt.Errorf("collecting for %s: %w", users[i].identity(), result.err))
+		}
+		if stream != nil {
+			// the beans and engagements are already out
+			if result.streamed_beans > 0 {
+				stats.Users += 1
+			}
+			stats.Beans += result.streamed_beans
+			stats.Engagements += result.streamed_engagements
+		} else if len(result.beans) > 0 {
+			// whatever got collected before a cancellation still gets stored
+			if err := collector.sink.Store(context.WithoutCancel(ctx), result.beans); err != nil {
+				log.Printf("Storing failed for u/%s: %v\n", users[i].Username, err)
+				errs = append(errs, fmt.Errorf("storing for %s: %w", users[i].identity(), err))
+				store_failed = true
+				continue
+			}
+			stats.Users += 1
+			stats.Beans += len(result.beans)
+			log.Printf("Finished storing for u/%s\n", users[i].Username)
+		}
+		for key, items := range result.checkpoints {
+			checkpoints[key] = append(checkpoints[key], items...)
+		}
+		if len(result.engagements) > 0 && collector.config.EngagementFunc != nil && users[i].UserId != _MASTER_COLLECTOR {
+			collector.config.EngagementFunc(result.engagements)
+			stats.Engagements += len(result.engagements)
 		}
 	}
+	// subreddits and profiles are shared across accounts. a failed account may have been the one that collected their posts
+	if !store_failed {
+		for key, items := range checkpoints {
+			collector.advanceCheckpoint(key, items)
+		}
+	}
+
+	if err := ctx.Err(); err != nil {
+		log.Printf("Collection cancelled after %d users and %d contents: %v\n", stats.Users, stats.Beans, err)
+		stats.CollectedFor = cache.collectedFor()
+		return stats, errors.Join(append(errs, err)...)
+	}
+	stats.CollectedFor = cache.collectedFor()
+	stats.Completed = true
+	return stats, errors.Join(errs...)
 }
 
-func (collector *RedditCollector) collectUser(user *RedditUser) ([]ds.Bean, []*oldds.UserEngagementItem) {
-	client, err := NewRedditClient(user, collector.config.RedditClientConfig)
+// collects everything it can for the user. the returned error joins the failures of the individual items
+// the beans that did get collected are returned regardless, sorted by url so that the output does not depend on scheduling
+// subreddits and posts are collected ItemConcurrency at a time
+// items another account already collected in the same run through the cache are not returned again. they only get the user recorded
+// the job's targets are collected along with the subscriptions unless it is targets only
+// with emit the beans and engagements are sent out as soon as they are collected instead of being returned
+func (collector *RedditCollector) collectUser(ctx context.Context, user *RedditUser, job *collectionJob, cache *collectionCache, emit func(CollectionEvent)) (userCollection, error) {
+	client, err := NewRedditClientContext(ctx, user, collector.config.RedditClientConfig)
 	if err != nil {
-		return nil, nil
+		return userCollection{}, err
 	}
-
-	var beans, engagements = make(map[string]ds.Bean), make(map[string]*oldds.UserEngagementItem)
-	collect := func(reddit_item *RedditItem, collect_similar bool) []RedditItem {
-		//check cache
-		if _, ok := beans[reddit_item.Name]; !ok {
-			bean, eng, children := collectRedditItem(client, reddit_item, collect_similar)
-			// if we can't build a digest then we will not send it
-			if len(bean.Text) >= MIN_TEXT_LENGTH {
-				beans[reddit_item.Name] = *bean
+	// long collections outlive the access token. keep the account list up to date with whatever the client refreshes
+	collector.updateCollectionAccountTokens(*client.User)
+	client.OnTokenRefresh(collector.updateCollectionAccountTokens)
+
+	// guards the caches and errs below
+	// events are sent while holding it so that a slow reader slows down the collection instead of piling up beans
+	var lock sync.Mutex
+	var collection = userCollection{checkpoints: make(map[string][]RedditItem)}
+	var visited = make(map[string]bool) // items this user has already gone through
+	// items fetched with this user's token. the vote, save, hide and subscription flags of the rest are another account's
+	var own = make(map[string]bool)
+	own_items := func(items []RedditItem) {
+		for _, item := range items {
+			own[item.Name] = true
+		}
+	}
+	// the flags only count for items of this user's own. history has the rest of them
+	item_engagements := func(item *RedditItem) []*oldds.UserEngagementItem {
+		if own[item.Name] {
+			return item.toUserEngagements(client.User)
+		}
+		return item.withoutUserFlags().toUserEngagements(client.User)
+	}
+	add_bean := func(bean *ds.Bean) {
+		if emit != nil {
+			emit(CollectionEvent{Kind: EVENT_BEAN, UserId: user.UserId, Bean: bean})
+			collection.streamed_beans += 1
+		} else {
+			collection.beans = append(collection.beans, *bean)
+		}
+	}
+	// the same engagement can be found in the item itself and in the history. keep one of each
+	var seen_engagements = make(map[string]bool)
+	add_engagements := func(engs []*oldds.UserEngagementItem) {
+		for _, eng := range engs {
+			if seen_engagements[eng.ContentId+"/"+eng.Action] {
+				continue
 			}
-			if eng != nil {
-				engagements[reddit_item.Name] = eng
+			seen_engagements[eng.ContentId+"/"+eng.Action] = true
+			if emit == nil {
+				collection.engagements = append(collection.engagements, eng)
+			} else if user.UserId != _MASTER_COLLECTOR {
+				// same as EngagementFunc, the master's engagements are not a real user's
+				emit(CollectionEvent{Kind: EVENT_ENGAGEMENT, UserId: user.UserId, Engagement: eng})
+				collection.streamed_engagements += 1
 			}
-			return children
 		}
-		return nil
+	}
+	var errs []error
+	add_error := func(err error) {
+		errs = append(errs, err)
+		if emit != nil {
+			emit(CollectionEvent{Kind: EVENT_ITEM_FAILED, UserId: user.UserId, Err: err})
+		}
+	}
+	// returns the children to explore and false if the item could not be collected
+	collect := func(reddit_item *RedditItem) ([]RedditItem, bool) {
+		// nothing collected after cancellation is complete enough to store
+		if ctx.Err() != nil {
+			return nil, false
+		}
+		//check cache
+		lock.Lock()
+		if visited[reddit_item.Name] {
+			lock.Unlock()
+			return nil, true
+		}
+		visited[reddit_item.Name] = true
+		lock.Unlock()
+
+		// another account may have collected the item already in this run. if so only the engagement is this user's own
+		// its children come with that account's flags
+		entry, owner := cache.claim(reddit_item, user.UserId)
+		for !owner {
+			<-entry.done
+			if entry.err == nil {
+				lock.Lock()
+				add_engagements(item_engagements(reddit_item))
+				lock.Unlock()
+				return entry.children, true
+			}
+			// the other account failed. give it a go with this one
+			entry, owner = cache.claim(reddit_item, user.UserId)
+		}
+
+		bean, children, err := collectRedditItem(ctx, client, reddit_item, &job.policy)
+		// the requests for this item may have been cut short. don't store half collected items
+		if err == nil && ctx.Err() != nil {
+			err = ctx.Err()
+		}
+		// if we can't build a digest then we will not send it
+		if err != nil || len(bean.Text) < job.policy.MinTextLength {
+			cache.finish(entry, "", children, err)
+		} else {
+			cache.finish(entry, bean.Url, children, nil)
+		}
+		if ctx.Err() != nil {
+			return nil, false
+		}
+
+		lock.Lock()
+		defer lock.Unlock()
+		if err != nil {
+			add_error(fmt.Errorf("%s: %w", reddit_item.Name, err))
+			return nil, false
+		}
+		if len(bean.Text) >= job.policy.MinTextLength {
+			add_bean(bean)
+		}
+		add_engagements(item_engagements(reddit_item))
+		own_items(children)
+		return children, true
 	}
 
 	log.Printf("Starting collection for u/%s\n", client.User.Username)
 
-	var subreddits, _ = client.Subreddits()
+	var subreddits []RedditItem
+	var target_posts []targetPosts
+	if !job.targets_only {
+		// walk through all the pages of subscriptions instead of stopping at the first 25
+		subscriptions := func(options ListingOptions) (Listing, error) { return client.SubredditsContext(ctx, options) }
+		if user.AppOnly {
+			// app-only tokens have no subscriptions. go with what reddit shows to logged out users
+			subscriptions = func(options ListingOptions) (Listing, error) { return client.DefaultSubredditsContext(ctx, options) }
+		}
+		var err_subscriptions error
+		subreddits, err_subscriptions = NewListingIterator(subscriptions, ListingOptions{Limit: MAX_PAGE_LIMIT}, 0).All()
+		if err_subscriptions != nil && ctx.Err() == nil {
+			// still go through the pages that did get loaded
+			add_error(fmt.Errorf("subscriptions: %w", err_subscriptions))
+		}
+	}
+	if len(job.targets) > 0 {
+		target_subreddits, posts, err_targets := resolveTargets(ctx, client, job.targets, &job.policy)
+		if err_targets != nil && ctx.Err() == nil {
+			// still go through the targets that did resolve
+			add_error(fmt.Errorf("targets: %w", err_targets))
+		}
+		subreddits = append(subreddits, target_subreddits...)
+		target_posts = posts
+	}
+	own_items(subreddits)
+	for _, target := range target_posts {
+		own_items(target.posts)
+	}
+
+	// a task holds a worker slot only while collecting its own item so that subreddits waiting on their posts can't starve them
+	workers := make(chan struct{}, concurrency(collector.config.ItemConcurrency))
+	var tasks sync.WaitGroup
+	run := func(reddit_item RedditItem) ([]RedditItem, bool) {
+		workers <- struct{}{}
+		defer func() { <-workers }()
+		return collect(&reddit_item)
+	}
 	for _, sr := range subreddits {
-		// TODO: disabling collection of similar subreddits for now. enable it later
-		children := collect(&sr, false)
-		var post_remaining = MAX_POST_LIMIT
-		for _, child := range children {
-			// collect if its a HOt POST with top or if its POST
-			// collect if its a SUBREDDIT with at least min-subscribers
-			if child.Kind == POST && post_remaining > 0 {
-				post_remaining -= 1
-				collect(&child, false)
-			} else if child.Kind == SUBREDDIT && child.NumSubscribers >= MIN_SUBSCRIBER_LIMIT {
-				collect(&child, false)
+		if ctx.Err() != nil {
+			break
+		}
+		tasks.Add(1)
+		go func(sr RedditItem) {
+			defer tasks.Done()
+			// similar subreddits come back as children if the policy asks for them
+			children, ok := run(sr)
+			// posts that earlier runs collected and that are past the freshness window are left alone
+			checkpoint_key := subredditCheckpointKey(sr.DisplayName)
+			checkpoint := cache.checkpoint(checkpoint_key, collector.checkpoint)
+			// each sort gets its share of posts
+			var post_remaining = 0
+			for _, sort := range job.policy.postSorts(sr.DisplayName) {
+				post_remaining += sort.Limit
+			}
+			var posts []RedditItem
+			var post_tasks sync.WaitGroup
+			var post_failed atomic.Bool
+			for _, child := range children {
+				// collect if its a POST within the limit
+				// collect if its a SUBREDDIT with at least min-subscribers
+				if child.Kind == POST && post_remaining > 0 && !job.policy.SubredditsOnly && !collector.isCollected(&child, checkpoint) {
+					post_remaining -= 1
+					posts = append(posts, child)
+				} else if !(child.Kind == SUBREDDIT && child.NumSubscribers >= job.policy.MinSubscribers) {
+					continue
+				}
+				tasks.Add(1)
+				post_tasks.Add(1)
+				go func(child RedditItem) {
+					defer tasks.Done()
+					defer post_tasks.Done()
+					if _, ok := run(child); !ok {
+						post_failed.Store(true)
+					}
+				}(child)
 			}
+			// move the checkpoint only once every post up to it made it. otherwise the next run would skip the failed ones
+			// the same subreddit can come up more than once e.g. as a subscription and as a target
+			// only the goroutine that listed its posts has any. the others must not wipe them out
+			post_tasks.Wait()
+			if ok && len(posts) > 0 && !post_failed.Load() && ctx.Err() == nil {
+				lock.Lock()
+				collection.checkpoints[checkpoint_key] = append(collection.checkpoints[checkpoint_key], posts...)
+				lock.Unlock()
+			}
+		}(sr)
+	}
+	for _, target := range target_posts {
+		if ctx.Err() != nil {
+			break
 		}
+		tasks.Add(1)
+		go func(target targetPosts) {
+			defer tasks.Done()
+			// profiles skip the posts earlier runs collected the same way subreddits do
+			var checkpoint *Checkpoint
+			if target.checkpoint_key != "" {
+				checkpoint = cache.checkpoint(target.checkpoint_key, collector.checkpoint)
+			}
+			var posts []RedditItem
+			var post_tasks sync.WaitGroup
+			var post_failed atomic.Bool
+			for _, post := range target.posts {
+				if collector.isCollected(&post, checkpoint) {
+					continue
+				}
+				posts = append(posts, post)
+				post_tasks.Add(1)
+				go func(post RedditItem) {
+					defer post_tasks.Done()
+					if _, ok := run(post); !ok {
+						post_failed.Store(true)
+					}
+				}(post)
+			}
+			post_tasks.Wait()
+			if target.checkpoint_key != "" && len(posts) > 0 && !post_failed.Load() && ctx.Err() == nil {
+				lock.Lock()
+				collection.checkpoints[target.checkpoint_key] = append(collection.checkpoints[target.checkpoint_key], posts...)
+				lock.Unlock()
+			}
+		}(target)
 	}
+	tasks.Wait()
+
+	// votes, saves and comments on items that weren't collected only show up in the history
+	// app-only tokens have none and the master's engagements don't get sent anyway
+	if !user.AppOnly && user.UserId != _MASTER_COLLECTOR && ctx.Err() == nil {
+		history, err := collectHistoryEngagements(ctx, client, job.policy.MaxHistoryItems)
+		if err != nil && ctx.Err() == nil {
+			add_error(err)
+		}
+		add_engagements(history)
+	}
+
+	sort.Slice(collection.beans, func(i, j int) bool { return collection.beans[i].Url < collection.beans[j].Url })
+	sort.Slice(collection.engagements, func(i, j int) bool {
+		return collection.engagements[i].ContentId+"/"+collection.engagements[i].Action < collection.engagements[j].ContentId+"/"+collection.engagements[j].Action
+	})
 
-	_, res_beans := datautils.MapToArray[string, ds.Bean](beans)
-	_, res_engagements := datautils.MapToArray[string, *oldds.UserEngagementItem](engagements)
+	log.Printf("Finished collection for u/%s | %d contents, %d engagements\n", client.User.Username,
+		len(collection.beans)+collection.streamed_beans, len(collection.engagements)+collection.streamed_engagements)
+	return collection, errors.Join(errs...)
+}
+
+// what collectUser found for an account
+type userCollection struct {
+	beans       []ds.Bean
+	engagements []*oldds.UserEngagementItem
+	checkpoints map[string][]RedditItem // checkpoint key -> the items to advance it with once the beans are stored
+	// number of beans and engagements sent out as events instead
+	streamed_beans       int
+	streamed_engagements int
+}
 
-	log.Printf("Finished collection for u/%s | %d contents, %d engagements\n", client.User.Username, len(res_beans), len(res_engagements))
-	return res_beans, res_engagements
+// number of workers for a configured concurrency. anything below 1 means sequential
+func concurrency(configured int) int {
+	if configured < 1 {
+		return 1
+	}
+	return configured
 }
 
-func collectRedditItem(client *RedditClient, item *RedditItem, collect_similar bool) (*ds.Bean, *oldds.UserEngagementItem, []RedditItem) {
+func collectRedditItem(ctx context.Context, client *RedditClient, item *RedditItem, policy *CollectionPolicy) (*ds.Bean, []RedditItem, error) {
 	var bean *ds.Bean
 	var children []RedditItem
 	// if it is a subreddit then get the top X posts
 	switch item.Kind {
 	case SUBREDDIT:
-		// load the hot posts in this subreddit
-		posts, _ := client.Posts(item, HOT)
-		// log.Println(len(posts), "HOT posts collected for", item.DisplayNamePrefixed)
-		bean = item.toBean(posts)
+		// load the posts in this subreddit for each sort. the same post can show up under multiple sorts
+		var posts []RedditItem
+		seen := make(map[string]bool)
+		// each sort is limited to only as many as will get collected
+		for _, sort := range policy.postSorts(item.DisplayName) {
+			posts_listing, err := client.PostsContext(ctx, item, sort)
+			if err != nil {
+				return nil, nil, err
+			}
+			for _, post := range posts_listing.Items {
+				if !seen[post.Name] {
+					seen[post.Name] = true
+					posts = append(posts, post)
+				}
+			}
+		}
+		// log.Println(len(posts), "posts collected for", item.DisplayNamePrefixed)
+		bean = item.toBean(posts, policy)
 
-		if collect_similar {
+		if policy.CollectSimilar {
 			// now collect the similar subreddits as well to return as part of the RedditItems to explore
-			similar, _ := client.SimilarSubreddits(item)
+			similar_listing, err := client.SimilarSubredditsContext(ctx, item, ListingOptions{})
+			if err != nil {
+				return nil, nil, err
+			}
+			similar := similar_listing.Items
 			// log.Println(len(similar), "similar subreddits collected for", item.DisplayNamePrefixed)
 			children = append(posts, similar...)
 		} else {
@@ -148,31 +559,41 @@ func collectRedditItem(client *RedditClient, item *RedditItem, collect_similar b
 		}
 	default:
 		// retrieve comments from this post
-		comments, _ := client.RetrieveComments(item)
+		tree, err := client.CommentTreeContext(ctx, item, CommentTreeOptions{MaxExpansions: policy.CommentExpansions})
+		if err != nil {
+			return nil, nil, err
+		}
+		comments := tree.Flatten()
+		if policy.CommentDepth > 0 {
+			// Depth is 0 for top level comments
+			comments = slices.DeleteFunc(comments, func(comment RedditItem) bool {
+				return tree.nodes[comment.Name].Depth >= policy.CommentDepth
+			})
+		}
 		// log.Println(len(comments), "comments collected for", item.Name, "in", item.SubredditPrefixed)
-		bean = item.toBean(comments) // safe_slice(comments, 0, MAX_CHILDREN_LIMIT))
+		bean = item.toBean(comments, policy) // safe_slice(comments, 0, MAX_CHILDREN_LIMIT))
 	}
 
-	return bean, item.toUserEngagement(client.User), children
+	return bean, children, nil
 }
 
 // DATA FORMAT TRANSFORMERS
-func (item *RedditItem) toBean(children []RedditItem) *ds.Bean {
+func (item *RedditItem) toBean(children []RedditItem, policy *CollectionPolicy) *ds.Bean {
 	// create the top level instance for item
 	return &ds.Bean{
 		Url:        item.contentUrl(),
 		Source:     REDDIT_SOURCE,
 		Title:      item.Title,
 		Kind:       item.kind(),
-		Text:       item.extractedText(),
+		Text:       item.extractedText(policy),
 		Author:     "u/" + item.Author,
 		Created:    int64(item.CreatedDate),
 		Keywords:   item.category(),
-		MediaNoise: item.toBeanMediaNoise(children),
+		MediaNoise: item.toBeanMediaNoise(children, policy),
 	}
 }
 
-func (item *RedditItem) toBeanMediaNoise(children []RedditItem) *ds.MediaNoise {
+func (item *RedditItem) toBeanMediaNoise(children []RedditItem, policy *CollectionPolicy) *ds.MediaNoise {
 	// special case arbiration functions
 	subscribers := func() int {
 		switch item.Kind {
@@ -203,30 +624,8 @@ func (item *RedditItem) toBeanMediaNoise(children []RedditItem) *ds.MediaNoise {
 		Subscribers:   subscribers(),
 		ThumbsupCount: item.Ups,
 		ThumbsupRatio: item.UpvoteRatio,
-		Digest:        item.digest(children),
-	}
-}
-
-func (item *RedditItem) toUserEngagement(user *RedditUser) *oldds.UserEngagementItem {
-	eng_item := &oldds.UserEngagementItem{
-		Username:   user.Username,
-		UserSource: REDDIT_SOURCE,
-		Source:     REDDIT_SOURCE,
-		ContentId:  item.Name,
-	}
-	switch item.Kind {
-	case SUBREDDIT:
-		if item.UserIsContributor || item.UserIsSubscriber || item.UserIsModerator {
-			eng_item.Action = "joined"
-			return eng_item
-		}
-	default:
-		if user.Username == item.Author {
-			eng_item.Action = "authored"
-			return eng_item
-		}
+		Digest:        item.digest(children, policy),
 	}
-	return nil
 }
 
 // FIELD EXTRACTION FUNCTIONS
@@ -287,7 +686,7 @@ func (item *RedditItem) kind() string {
 	}
 }
 
-func (item *RedditItem) digest(children []RedditItem) string {
+func (item *RedditItem) digest(children []RedditItem, policy *CollectionPolicy) string {
 	var builder strings.Builder
 	var body_text string
 
@@ -303,14 +702,14 @@ func (item *RedditItem) digest(children []RedditItem) string {
 	}
 
 	builder.WriteString(body_text)
-	max_counter := MAX_POST_LIMIT
+	max_counter := policy.DigestSize
 	for _, child := range children {
-		child_text := datautils.TruncateTextWithEllipsis(child.extractedText(), MAX_CHILD_TEXT_LENGTH)
+		child_text := datautils.TruncateTextWithEllipsis(child.extractedText(policy), policy.MaxChildTextLength)
 		max_counter -= 1
-		if len(child_text) >= MIN_TEXT_LENGTH {
+		if len(child_text) >= policy.MinTextLength {
 			builder.WriteString(fmt.Sprintf("%s: %s\n\n", child.Kind, child_text))
 		}
-		if builder.Len() >= MAX_DIGEST_TEXT_LENGTH || max_counter <= 0 {
+		if builder.Len() >= policy.MaxDigestLength || max_counter <= 0 {
 			// it will overflow a bit but thats okay since embeddings does its own truncation
 			break
 		}
@@ -318,9 +717,10 @@ func (item *RedditItem) digest(children []RedditItem) string {
 	return builder.String()
 }
 
-var url_collector = dl.NewRedditLinkLoader()
+// the loaders keep state while loading a document so each concurrent load gets its own
+var url_collectors = sync.Pool{New: func() any { return dl.NewRedditLinkLoader() }}
 
-func (item *RedditItem) extractedText() string {
+func (item *RedditItem) extractedText(policy *CollectionPolicy) string {
 	if item.ExtractedText == "" {
 		var temp_text string
 		switch item.Kind {
@@ -332,12 +732,14 @@ func (item *RedditItem) extractedText() string {
 				temp_text = extractTextFromHtml(item.PostTextHtml)
 			} else if item.Url != "" {
 				// this is link to a new article posted in reddit
+				url_collector := url_collectors.Get().(*dl.WebLoader)
 				temp_text = url_collector.LoadDocument(item.Url).Text
+				url_collectors.Put(url_collector)
 			}
 		case COMMENT:
 			temp_text = extractTextFromHtml(item.CommentBodyHtml)
 		}
-		item.ExtractedText = cleanupText(temp_text, MAX_EXTRACTED_TEXT_LENGTH)
+		item.ExtractedText = cleanupText(temp_text, policy.MaxTextLength)
 	}
 
 	return item.ExtractedText
@@ -0,0 +1,73 @@
+package sdk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	ds "github.com/soumitsalman/beansack/sdk"
+)
+
+func cancelledCollector(t *testing.T, user_concurrency int) (*RedditCollector, context.Context) {
+	t.Helper()
+	accounts := NewMemoryAccountStore()
+	for i := 0; i < 5; i++ {
+		if err := accounts.Put(RedditUser{UserId: fmt.Sprintf("user-%d", i), Username: fmt.Sprintf("user%d", i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	collector := NewCollector(CollectorConfig{
+		AccountStore:    accounts,
+		UserConcurrency: user_concurrency,
+		BeanSink:        StoreFunc(func([]ds.Bean) { t.Error("nothing should be stored") }),
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	return collector, ctx
+}
+
+// a run must come back right away with the context's error instead of hanging on the accounts it never started
+func TestCollectContextCancelled(t *testing.T) {
+	for _, user_concurrency := range []int{1, 3} {
+		collector, ctx := cancelledCollector(t, user_concurrency)
+		done := make(chan struct{})
+		var stats CollectionStats
+		var err error
+		go func() {
+			defer close(done)
+			stats, err = collector.CollectContext(ctx)
+		}()
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("CollectContext hung with UserConcurrency %d", user_concurrency)
+		}
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("expected context.Canceled, got %v", err)
+		}
+		if stats.Completed || stats.Users != 0 || stats.Beans != 0 {
+			t.Errorf("unexpected stats %+v", stats)
+		}
+	}
+}
+
+func TestStreamCancelled(t *testing.T) {
+	collector, ctx := cancelledCollector(t, 2)
+	stream := collector.Stream(ctx, 0)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for range stream.Events {
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Stream hung")
+	}
+	if _, err := stream.Result(); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+}
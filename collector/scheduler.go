@@ -0,0 +1,171 @@
+package sdk
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/rand"
+	"time"
+)
+
+const (
+	// interval of the default schedule when CollectorConfig.Schedules is empty
+	DEFAULT_COLLECTION_INTERVAL = time.Hour
+	DEFAULT_SCHEDULE            = "default"
+)
+
+// a collection that Run repeats on its own interval e.g. hot posts every 15 minutes and subreddit metadata daily
+type CollectionSchedule struct {
+	// identifies the schedule in the last-run record. has to be unique
+	Name     string
+	Interval time.Duration
+	// each run starts up to this much later than due so that instances restarted together don't crawl in lockstep
+	// defaults to a tenth of the Interval
+	Jitter time.Duration
+	// overrides for the runs of this schedule. nil or empty means the ones in CollectorConfig
+	// TargetsOnly only applies along with Targets
+	Policy      *CollectionPolicy
+	Targets     []CollectionTarget
+	TargetsOnly bool
+}
+
+// what a single run collects. the config with the overrides of a schedule applied
+type collectionJob struct {
+	policy       CollectionPolicy // with the defaults filled in
+	targets      []CollectionTarget
+	targets_only bool
+}
+
+func (collector *RedditCollector) newJob(schedule *CollectionSchedule) (collectionJob, error) {
+	job := collectionJob{
+		policy:       collector.config.Policy,
+		targets:      collector.config.Targets,
+		targets_only: collector.config.TargetsOnly,
+	}
+	if schedule != nil {
+		if schedule.Policy != nil {
+			job.policy = *schedule.Policy
+		}
+		if len(schedule.Targets) > 0 {
+			job.targets = schedule.Targets
+			job.targets_only = schedule.TargetsOnly
+		}
+	}
+	if err := job.policy.Validate(); err != nil {
+		return job, err
+	}
+	job.policy = job.policy.withDefaults()
+	return job, nil
+}
+
+// the schedules from the config or a single DEFAULT_SCHEDULE of the whole config every DEFAULT_COLLECTION_INTERVAL
+func (collector *RedditCollector) schedules() ([]CollectionSchedule, error) {
+	if len(collector.config.Schedules) == 0 {
+		return []CollectionSchedule{{Name: DEFAULT_SCHEDULE, Interval: DEFAULT_COLLECTION_INTERVAL}}, nil
+	}
+	names := make(map[string]bool)
+	for _, schedule := range collector.config.Schedules {
+		if schedule.Name == "" || names[schedule.Name] {
+			return nil, fmt.Errorf("schedule names have to be unique and not empty: %q", schedule.Name)
+		}
+		if schedule.Interval <= 0 {
+			return nil, fmt.Errorf("schedule %s needs a positive Interval", schedule.Name)
+		}
+		names[schedule.Name] = true
+	}
+	return collector.config.Schedules, nil
+}
+
+func scheduleCheckpointKey(name string) string {
+	return "schedule/" + name
+}
+
+// repeats the collection on the schedules of the config until ctx is done
+// runs never overlap. when several schedules are due they run one after the other, the most overdue first
+// the start of each completed run is recorded in the CheckpointStore so that a restart picks up where the schedules were
+// instead of crawling everything again. without a CheckpointStore every schedule runs right after the start
+// on shutdown the run in progress stores what it has collected so far. Run returns after that with ctx's error
+// beans left in the dead letter directory by earlier runs are stored first. the ones that still fail stay there
+func (collector *RedditCollector) Run(ctx context.Context) error {
+	schedules, err := collector.schedules()
+	if err != nil {
+		return err
+	}
+	for _, schedule := range schedules {
+		if _, err := collector.newJob(&schedule); err != nil {
+			return fmt.Errorf("schedule %s: %w", schedule.Name, err)
+		}
+	}
+	if err := collector.ReplayDeadLetters(ctx); err != nil {
+		log.Println("Failed replaying dead letters.", err)
+	}
+
+	next := make([]time.Time, len(schedules))
+	for i := range schedules {
+		last := time.Time{}
+		if checkpoint := collector.checkpoint(scheduleCheckpointKey(schedules[i].Name)); checkpoint != nil {
+			last = time.Unix(checkpoint.Updated, 0)
+		}
+		next[i] = schedules[i].nextRun(last)
+	}
+
+	for {
+		due := 0
+		for i := range next {
+			if next[i].Before(next[due]) {
+				due = i
+			}
+		}
+		schedule := &schedules[due]
+		log.Printf("Next collection for schedule %s at %s\n", schedule.Name, next[due].Format(time.RFC3339))
+		timer := time.NewTimer(time.Until(next[due]))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			log.Println("Collection scheduler stopped")
+			return ctx.Err()
+		case <-timer.C:
+		}
+
+		started := time.Now()
+		stats, err := collector.collect(ctx, nil, schedule)
+		if err != nil {
+			log.Printf("Collection for schedule %s had failures: %v\n", schedule.Name, err)
+		}
+		// a run cut short gets repeated after a restart
+		if stats.Completed {
+			collector.recordRun(schedule.Name, started)
+		}
+		log.Printf("Collection for schedule %s took %s | %d users, %d contents\n", schedule.Name, time.Since(started).Round(time.Second), stats.Users, stats.Beans)
+		next[due] = schedule.nextRun(started)
+		if ctx.Err() != nil {
+			log.Println("Collection scheduler stopped")
+			return ctx.Err()
+		}
+	}
+}
+
+// a zero last means the schedule never ran
+func (schedule *CollectionSchedule) nextRun(last time.Time) time.Time {
+	jitter := schedule.Jitter
+	if jitter <= 0 {
+		jitter = schedule.Interval / 10
+	}
+	next := time.Now()
+	if !last.IsZero() {
+		next = last.Add(schedule.Interval)
+	}
+	if jitter > 0 {
+		next = next.Add(time.Duration(rand.Int63n(int64(jitter))))
+	}
+	return next
+}
+
+func (collector *RedditCollector) recordRun(name string, started time.Time) {
+	if collector.config.CheckpointStore == nil {
+		return
+	}
+	if err := collector.config.CheckpointStore.Put(scheduleCheckpointKey(name), Checkpoint{Updated: started.Unix()}); err != nil {
+		log.Println("failed recording the run of schedule", name, err)
+	}
+}
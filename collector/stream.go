@@ -0,0 +1,108 @@
+package sdk
+
+import (
+	"context"
+	"sync"
+
+	ds "github.com/soumitsalman/beansack/sdk"
+	oldds "github.com/soumitsalman/media-content-service/api"
+)
+
+// kinds of CollectionEvent
+const (
+	EVENT_BEAN          = "bean"
+	EVENT_ENGAGEMENT    = "engagement"
+	EVENT_ITEM_FAILED   = "item_failed"
+	EVENT_USER_STARTED  = "user_started"
+	EVENT_USER_FINISHED = "user_finished"
+)
+
+// something that happened during a streamed collection
+type CollectionEvent struct {
+	Kind       string
+	UserId     string                    // account the event is for
+	Bean       *ds.Bean                  // only for EVENT_BEAN
+	Engagement *oldds.UserEngagementItem // only for EVENT_ENGAGEMENT
+	Err        error                     // why the item failed for EVENT_ITEM_FAILED. the account's joined failures for EVENT_USER_FINISHED
+	Progress   CollectionProgress        // totals of the run including this event
+}
+
+// running totals of a streamed collection
+type CollectionProgress struct {
+	UsersStarted  int
+	UsersFinished int
+	Beans         int
+	Engagements   int
+	Failures      int // items that could not be collected
+}
+
+// a collection run that hands out beans, engagements and progress as they happen instead of storing them through the BeanSink
+// beans are not kept around after they are sent so memory stays bounded by the buffer of Events
+// checkpoints of an account move once all of its events have been sent
+type CollectionStream struct {
+	// closed when the run is over. keep reading until then or cancel the context of the run
+	Events <-chan CollectionEvent
+
+	ctx      context.Context
+	events   chan CollectionEvent
+	lock     sync.Mutex
+	progress CollectionProgress
+	done     chan struct{}
+	stats    CollectionStats
+	err      error
+}
+
+// starts collecting in the background. buffer is the number of events that can wait in Events before the collection slows down
+func (collector *RedditCollector) Stream(ctx context.Context, buffer int) *CollectionStream {
+	events := make(chan CollectionEvent, max(buffer, 0))
+	stream := &CollectionStream{
+		Events: events,
+		ctx:    ctx,
+		events: events,
+		done:   make(chan struct{}),
+	}
+	go func() {
+		defer close(stream.done)
+		defer close(stream.events)
+		stream.stats, stream.err = collector.collect(ctx, stream, nil)
+	}()
+	return stream
+}
+
+// waits for the run to be over and returns the same as CollectContext would. Events has to be drained for the run to finish
+func (stream *CollectionStream) Result() (CollectionStats, error) {
+	<-stream.done
+	return stream.stats, stream.err
+}
+
+// sends the event with the updated totals. events are dropped once the context is cancelled since nobody may be reading any more
+func (stream *CollectionStream) emit(event CollectionEvent) {
+	stream.lock.Lock()
+	switch event.Kind {
+	case EVENT_BEAN:
+		stream.progress.Beans += 1
+	case EVENT_ENGAGEMENT:
+		stream.progress.Engagements += 1
+	case EVENT_ITEM_FAILED:
+		stream.progress.Failures += 1
+	case EVENT_USER_STARTED:
+		stream.progress.UsersStarted += 1
+	case EVENT_USER_FINISHED:
+		stream.progress.UsersFinished += 1
+	}
+	event.Progress = stream.progress
+	stream.lock.Unlock()
+
+	select {
+	case stream.events <- event:
+	case <-stream.ctx.Done():
+	}
+}
+
+// nil when there is no stream so that collectUser can tell the modes apart
+func (stream *CollectionStream) emitter() func(CollectionEvent) {
+	if stream == nil {
+		return nil
+	}
+	return stream.emit
+}
@@ -0,0 +1,127 @@
+package sdk
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// what to post with Submit. a post with a Url is a link post, otherwise it is a self post with Text
+type SubmitOptions struct {
+	Subreddit string // display name e.g. "golang". the "r/" prefix is optional
+	Title     string
+	Text      string // markdown body of a self post
+	Url       string
+	// the id of one of the subreddit's flair templates. FlairText overrides the template's text if the template allows it
+	FlairId   string
+	FlairText string
+	Nsfw      bool
+	Spoiler   bool
+	// don't send the replies to the post to the author's inbox. reddit sends them by default
+	NoInboxReplies bool
+	// post the link even if it has already been posted to the subreddit. reddit rejects it with ALREADY_SUB otherwise
+	Resubmit bool
+}
+
+// posts to a subreddit as the user in the client and returns the new post
+// needs the submit scope. accounts linked before SCOPE asked for it get ErrForbidden until they are linked again
+// failures that reddit reports in json.errors come back as *RedditFormError
+func (client *RedditClient) Submit(options SubmitOptions) (RedditItem, error) {
+	return client.SubmitContext(context.Background(), options)
+}
+
+func (client *RedditClient) SubmitContext(ctx context.Context, options SubmitOptions) (RedditItem, error) {
+	params := map[string]string{
+		"api_type":    "json",
+		"sr":          strings.TrimPrefix(options.Subreddit, "r/"),
+		"title":       options.Title,
+		"nsfw":        strconv.FormatBool(options.Nsfw),
+		"spoiler":     strconv.FormatBool(options.Spoiler),
+		"sendreplies": strconv.FormatBool(!options.NoInboxReplies),
+		"resubmit":    strconv.FormatBool(options.Resubmit),
+	}
+	if options.Url != "" {
+		params["kind"] = "link"
+		params["url"] = options.Url
+	} else {
+		params["kind"] = "self"
+		params["text"] = options.Text
+	}
+	if options.FlairId != "" {
+		params["flair_id"] = options.FlairId
+	}
+	if options.FlairText != "" {
+		params["flair_text"] = options.FlairText
+	}
+
+	var result struct {
+		Json struct {
+			Errors [][]string `json:"errors"`
+			Data   struct {
+				Name string `json:"name"`
+			} `json:"data"`
+		} `json:"json"`
+	}
+	if err := client.write(ctx, "/api/submit", params, &result, &result.Json.Errors); err != nil {
+		return RedditItem{}, err
+	}
+
+	// /api/submit only returns the fullname and the url
+	listing, err := client.PostsByIdContext(ctx, result.Json.Data.Name)
+	if err != nil {
+		return RedditItem{Kind: POST, Name: result.Json.Data.Name}, err
+	}
+	if len(listing.Items) == 0 {
+		return RedditItem{Kind: POST, Name: result.Json.Data.Name}, fmt.Errorf("%w: submitted post %s", ErrNotFound, result.Json.Data.Name)
+	}
+	return listing.Items[0], nil
+}
+
+// comments on a post or replies to a comment as the user in the client and returns the new comment. needs the submit scope
+// text is markdown. failures that reddit reports in json.errors come back as *RedditFormError
+func (client *RedditClient) Reply(parent *RedditItem, text string) (RedditItem, error) {
+	return client.ReplyContext(context.Background(), parent, text)
+}
+
+func (client *RedditClient) ReplyContext(ctx context.Context, parent *RedditItem, text string) (RedditItem, error) {
+	var result struct {
+		Json struct {
+			Errors [][]string `json:"errors"`
+			Data   struct {
+				Things []struct {
+					Kind string     `json:"kind"`
+					Data RedditItem `json:"data"`
+				} `json:"things"`
+			} `json:"data"`
+		} `json:"json"`
+	}
+	params := map[string]string{
+		"api_type": "json",
+		"thing_id": parent.Name,
+		"text":     text,
+	}
+	if err := client.write(ctx, "/api/comment", params, &result, &result.Json.Errors); err != nil {
+		return RedditItem{}, err
+	}
+	if len(result.Json.Data.Things) == 0 {
+		return RedditItem{}, fmt.Errorf("reddit returned no comment for the reply to %s", parent.Name)
+	}
+	comment := result.Json.Data.Things[0].Data
+	comment.Kind = extractKind(result.Json.Data.Things[0].Kind)
+	return comment, nil
+}
+
+// posts the form and turns both non-2xx responses and json.errors into errors
+// only a 429 gets retried since reddit may have created the post or comment before failing in any other way
+// errs has to point into result since reddit puts json.errors next to the data
+func (client *RedditClient) write(ctx context.Context, url string, params map[string]string, result any, errs *[][]string) error {
+	if err := client.responseError(client.http_client.R().
+		SetContext(ctx).
+		SetFormData(params).
+		SetResult(result).
+		Post(url)); err != nil {
+		return err
+	}
+	return formError(*errs)
+}
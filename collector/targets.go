@@ -0,0 +1,178 @@
+package sdk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// kinds of CollectionTarget
+const (
+	TARGET_SUBREDDIT   = "subreddit"
+	TARGET_MULTIREDDIT = "multireddit"
+	TARGET_SEARCH      = "search"
+	TARGET_USER        = "user"
+	TARGET_POST        = "post"
+)
+
+// something to collect on top of (or instead of) the subscriptions of the accounts
+// subreddits, including the ones in a multireddit, are collected like subscribed subreddits i.e. along with their posts
+// searches, user profiles and post urls are collected as posts. profiles skip the posts earlier runs collected the same way subreddits do
+type CollectionTarget struct {
+	Kind string
+	// depends on the Kind
+	//   - TARGET_SUBREDDIT: display name e.g. "golang"
+	//   - TARGET_MULTIREDDIT: path e.g. "user/someone/m/news"
+	//   - TARGET_SEARCH: search query
+	//   - TARGET_USER: username
+	//   - TARGET_POST: url or fullname of the post
+	Name string
+	// limits a TARGET_SEARCH to this subreddit
+	Subreddit string
+	// sort and time for TARGET_SEARCH and TARGET_USER posts. left to reddit's defaults when empty
+	Sort string
+	Time string
+	// number of posts from a TARGET_SEARCH or TARGET_USER. defaults to the policy's PostsPerSubreddit
+	Limit int
+}
+
+var (
+	multireddit_path = regexp.MustCompile(`^/?(?:u|user)/([^/]+)/m/([^/]+)/?$`)
+	post_id          = regexp.MustCompile(`(?:/comments/|redd\.it/)([a-z0-9]+)`)
+)
+
+// parses the short forms
+//   - "r/golang" for a subreddit
+//   - "user/someone/m/news" or "u/someone/m/news" for a multireddit
+//   - "u/someone" or "user/someone" for a user profile
+//   - "search:some query" for a search across reddit
+//   - a reddit or redd.it url of a post
+func ParseCollectionTarget(value string) (CollectionTarget, error) {
+	value = strings.TrimSpace(value)
+	switch {
+	case strings.HasPrefix(value, "search:"):
+		return CollectionTarget{Kind: TARGET_SEARCH, Name: strings.TrimSpace(strings.TrimPrefix(value, "search:"))}, nil
+	case strings.HasPrefix(value, "http://") || strings.HasPrefix(value, "https://"):
+		if _, err := url.Parse(value); err != nil || !post_id.MatchString(value) {
+			return CollectionTarget{}, fmt.Errorf("not a url of a reddit post: %s", value)
+		}
+		return CollectionTarget{Kind: TARGET_POST, Name: value}, nil
+	case multireddit_path.MatchString(value):
+		match := multireddit_path.FindStringSubmatch(value)
+		return CollectionTarget{Kind: TARGET_MULTIREDDIT, Name: fmt.Sprintf("user/%s/m/%s", match[1], match[2])}, nil
+	}
+
+	prefix, name, found := strings.Cut(strings.Trim(value, "/"), "/")
+	if found && name != "" && !strings.Contains(name, "/") {
+		switch prefix {
+		case "r":
+			return CollectionTarget{Kind: TARGET_SUBREDDIT, Name: name}, nil
+		case "u", "user":
+			return CollectionTarget{Kind: TARGET_USER, Name: name}, nil
+		}
+	}
+	return CollectionTarget{}, fmt.Errorf("unknown collection target: %s", value)
+}
+
+// fullname of the post a TARGET_POST points to
+func (target CollectionTarget) postName() (string, error) {
+	if strings.HasPrefix(target.Name, "t3_") {
+		return target.Name, nil
+	}
+	if match := post_id.FindStringSubmatch(target.Name); match != nil {
+		return "t3_" + match[1], nil
+	}
+	return "", fmt.Errorf("not a url of a reddit post: %s", target.Name)
+}
+
+// posts of a target. profiles have a checkpoint like subreddits do. searches and single posts don't
+type targetPosts struct {
+	checkpoint_key string
+	posts          []RedditItem
+}
+
+// loads the subreddits and posts the targets point to. a failing target doesn't stop the others
+// the returned error joins the failures of the individual targets
+func resolveTargets(ctx context.Context, client *RedditClient, targets []CollectionTarget, policy *CollectionPolicy) ([]RedditItem, []targetPosts, error) {
+	var subreddits []RedditItem
+	var posts []targetPosts
+	var subreddit_names, post_names []string
+	var errs []error
+	for _, target := range targets {
+		if ctx.Err() != nil {
+			return subreddits, posts, ctx.Err()
+		}
+		limit := target.Limit
+		if limit <= 0 {
+			limit = policy.PostsPerSubreddit
+		}
+		switch target.Kind {
+		case TARGET_SUBREDDIT:
+			subreddit_names = append(subreddit_names, target.Name)
+		case TARGET_MULTIREDDIT:
+			names, err := client.MultiredditSubredditsContext(ctx, target.Name)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("multireddit %s: %w", target.Name, err))
+				continue
+			}
+			subreddit_names = append(subreddit_names, names...)
+		case TARGET_SEARCH:
+			var subreddit *RedditItem
+			if target.Subreddit != "" {
+				subreddit = &RedditItem{DisplayNamePrefixed: "r/" + target.Subreddit}
+			}
+			listing, err := client.SearchPostsContext(ctx, subreddit, SearchOptions{
+				Query:             target.Name,
+				Sort:              target.Sort,
+				Time:              target.Time,
+				RestrictSubreddit: subreddit != nil,
+				ListingOptions:    ListingOptions{Limit: limit},
+			})
+			if err != nil {
+				errs = append(errs, fmt.Errorf("search %q: %w", target.Name, err))
+				continue
+			}
+			posts = append(posts, targetPosts{posts: listing.Items})
+		case TARGET_USER:
+			listing, err := client.UserPostsContext(ctx, target.Name, PostsOptions{
+				Sort:           target.Sort,
+				Time:           target.Time,
+				ListingOptions: ListingOptions{Limit: limit},
+			})
+			if err != nil {
+				errs = append(errs, fmt.Errorf("u/%s: %w", target.Name, err))
+				continue
+			}
+			posts = append(posts, targetPosts{checkpoint_key: userCheckpointKey(target.Name), posts: listing.Items})
+		case TARGET_POST:
+			name, err := target.postName()
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			post_names = append(post_names, name)
+		default:
+			errs = append(errs, fmt.Errorf("unknown collection target kind %q", target.Kind))
+		}
+	}
+
+	// look up the subreddits and posts in bulk instead of one request each
+	if len(subreddit_names) > 0 {
+		listing, err := client.SubredditsByNameContext(ctx, subreddit_names...)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("subreddits: %w", err))
+		}
+		subreddits = append(subreddits, listing.Items...)
+	}
+	if len(post_names) > 0 {
+		listing, err := client.PostsByIdContext(ctx, post_names...)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("posts: %w", err))
+		}
+		posts = append(posts, targetPosts{posts: listing.Items})
+	}
+	return subreddits, posts, errors.Join(errs...)
+}
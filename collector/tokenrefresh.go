@@ -0,0 +1,207 @@
+package sdk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+)
+
+const (
+	// refresh the access token this long before reddit actually expires it
+	TOKEN_EXPIRY_MARGIN = 1 * time.Minute
+)
+
+// sets a handler that gets called every time the client obtains a new access token
+// use this to persist the new tokens of the user (e.g. in the collector's account list)
+// the handler gets a copy of the user and may make requests with the client or replace itself
+func (client *RedditClient) OnTokenRefresh(handler func(user RedditUser)) {
+	client.token_lock.Lock()
+	defer client.token_lock.Unlock()
+	client.on_token_refresh = handler
+}
+
+// sets the current access token on the request. if the token is about to expire it gets refreshed first
+func (client *RedditClient) authorizeRequest(req *resty.Request) error {
+	client.token_lock.Lock()
+	token, expiry := client.User.AccessToken, client.User.TokenExpiry
+	client.token_lock.Unlock()
+
+	if expiry > 0 && time.Now().Add(TOKEN_EXPIRY_MARGIN).Unix() >= expiry {
+		if err := client.refreshToken(req.Context(), token); err != nil {
+			// send the request with the old token anyway. reddit will respond with a 401 if it is no longer valid
+			log.Println("failed refreshing access token for", client.User.UserId, err)
+		}
+		client.token_lock.Lock()
+		token = client.User.AccessToken
+		client.token_lock.Unlock()
+	}
+	req.SetAuthToken(token)
+	return nil
+}
+
+// gets a new access token using the stored refresh token or the password
+// stale_token is the token the caller found to be expired. if another request has already replaced it, this is a no-op
+func (client *RedditClient) refreshToken(ctx context.Context, stale_token string) error {
+	refreshed, handler, err := client.replaceToken(ctx, stale_token)
+	if err != nil || refreshed == nil {
+		return err
+	}
+	// called without the lock so that the handler can use the client itself
+	if handler != nil {
+		handler(*refreshed)
+	}
+	return nil
+}
+
+// swaps in a new access token under the lock and returns a copy of the user along with the handler to call
+// the user is nil if stale_token had already been replaced
+func (client *RedditClient) replaceToken(ctx context.Context, stale_token string) (*RedditUser, func(user RedditUser), error) {
+	client.token_lock.Lock()
+	defer client.token_lock.Unlock()
+
+	if client.User.AccessToken != stale_token {
+		return nil, nil, nil
+	}
+	if !client.User.AppOnly && client.User.RefreshToken == "" && client.User.Password == "" {
+		return nil, nil, &RedditAuthenticationResult{FailureMessage: "Cannot refresh access token. Needs either AppOnly, RefreshToken or Username+Password."}
+	}
+
+	oauth_result, err := requestToken(ctx, client.User.authGrant(client.config), client.config)
+	if err != nil {
+		return nil, nil, err
+	}
+	client.User.setTokens(oauth_result)
+	log.Println("Refreshed access token for", client.User.UserId)
+
+	refreshed := *client.User
+	return &refreshed, client.on_token_refresh, nil
+}
+
+// revokes the refresh token (which also invalidates the access tokens issued from it) and the current access token
+// the client can't make any more requests afterwards
+func (client *RedditClient) Revoke() error {
+	return client.RevokeContext(context.Background())
+}
+
+func (client *RedditClient) RevokeContext(ctx context.Context) error {
+	client.token_lock.Lock()
+	defer client.token_lock.Unlock()
+
+	var errs []error
+	if client.User.RefreshToken != "" {
+		if err := revokeToken(ctx, client.User.RefreshToken, "refresh_token", client.config); err != nil {
+			errs = append(errs, fmt.Errorf("revoking refresh token: %w", err))
+		} else {
+			client.User.RefreshToken = ""
+		}
+	}
+	if client.User.AccessToken != "" {
+		if err := revokeToken(ctx, client.User.AccessToken, "access_token", client.config); err != nil {
+			errs = append(errs, fmt.Errorf("revoking access token: %w", err))
+		} else {
+			client.User.AccessToken = ""
+			client.User.TokenExpiry = 0
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// reddit responds with 204 whether or not the token was valid. anything else means the request itself failed
+func revokeToken(ctx context.Context, token, token_type string, client_config RedditClientConfig) error {
+	resp, err := resty.New().R().
+		SetContext(ctx).
+		SetBasicAuth(client_config.AppId, client_config.AppSecret).
+		SetHeader("User-Agent", client_config.AppName).
+		SetHeader("Content-Type", URL_ENCODED_BODY).
+		SetFormData(map[string]string{
+			"token":           token,
+			"token_type_hint": token_type,
+		}).
+		Post(REDDIT_OAUTH_REVOKE_URL)
+	if err != nil {
+		return err
+	}
+	if resp.IsError() {
+		return &RedditAPIError{StatusCode: resp.StatusCode(), Message: resp.Status()}
+	}
+	return nil
+}
+
+// calls the oauth endpoint with the given grant
+func requestToken(ctx context.Context, auth_grant map[string]string, client_config RedditClientConfig) (*RedditAuthenticationResult, error) {
+	var oauth_result RedditAuthenticationResult
+	resp, err := resty.New().R().
+		SetContext(ctx).
+		SetBasicAuth(client_config.AppId, client_config.AppSecret).
+		SetHeader("User-Agent", client_config.RedirectUri).
+		SetHeader("Content-Type", URL_ENCODED_BODY).
+		SetFormData(auth_grant).
+		SetResult(&oauth_result).
+		SetError(&oauth_result).
+		Post(REDDIT_OAUTH_URL)
+	if err != nil {
+		return nil, err
+	}
+
+	if oauth_result.AccessToken == "" {
+		if oauth_result.FailureMessage == "" {
+			oauth_result.FailureMessage = resp.Status()
+		}
+		oauth_result.StatusCode = resp.StatusCode()
+		return nil, &oauth_result
+	}
+	return &oauth_result, nil
+}
+
+// picks the grant that can produce a new access token for the user
+func (user *RedditUser) authGrant(client_config RedditClientConfig) map[string]string {
+	if user.AppOnly {
+		// confidential apps (web and script) have a secret. installed apps identify by device instead
+		if client_config.AppSecret != "" {
+			return map[string]string{
+				"grant_type": "client_credentials",
+				"scope":      client_config.Scope,
+			}
+		}
+		device_id := client_config.DeviceId
+		if device_id == "" {
+			device_id = DEFAULT_DEVICE_ID
+		}
+		return map[string]string{
+			"grant_type": "https://oauth.reddit.com/grants/installed_client",
+			"device_id":  device_id,
+			"scope":      client_config.Scope,
+		}
+	}
+	if user.RefreshToken != "" {
+		// log.Println("OAUTH with refresh_token")
+		return map[string]string{
+			"grant_type":    "refresh_token",
+			"refresh_token": user.RefreshToken,
+		}
+	}
+	// log.Println("OAUTH with password")
+	return map[string]string{
+		"grant_type": "password",
+		"username":   user.Username,
+		"password":   user.Password,
+		"scope":      client_config.Scope,
+	}
+}
+
+func (user *RedditUser) setTokens(oauth_result *RedditAuthenticationResult) {
+	user.AccessToken = oauth_result.AccessToken
+	// reddit does not send back a new refresh token when refreshing. keep the existing one in that case
+	if oauth_result.RefreshToken != "" {
+		user.RefreshToken = oauth_result.RefreshToken
+	}
+	if oauth_result.ExpiresIn > 0 {
+		user.TokenExpiry = time.Now().Unix() + oauth_result.ExpiresIn
+	} else {
+		user.TokenExpiry = 0
+	}
+}
@@ -1,9 +1,13 @@
 package examples
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net/http"
 	"os"
+	"os/signal"
 	"time"
 
 	ds "github.com/soumitsalman/beansack/sdk"
@@ -21,45 +25,33 @@ func localFileStore(contents []ds.Bean) {
 	os.WriteFile(filename, data, 0644)
 }
 
-// TODO: delete all of these
-// func redditOauthRedirectHandler(ctx *gin.Context) {
-// 	var params appAuthorizationParams
-// 	if ctx.BindQuery(&params) != nil || params.Error != "" {
-// 		ctx.String(http.StatusUnauthorized, "Some authorization issue")
-// 		return
-// 	}
-
-// 	client, err := sdk.NewOauthRedditClient(params.UserId, params.Code, config.RedditClientConfig)
-// 	if err != nil {
-// 		ctx.String(http.StatusUnauthorized, "Some authorization issue")
-// 		return
-// 	}
-
-// 	sdk.AddRedditUser(*client.User)
-// 	ctx.String(http.StatusOK, "Authentication Succeeded for %s. Feel Free to Close the Window.", params.UserId)
-// }
-
-// func userAuthCheckHandler(ctx *gin.Context) {
-// 	var params appAuthorizationParams
-// 	if ctx.BindQuery(&params) == nil {
-// 		ok, res := sdk.IsUserAuthenticated(params.UserId)
-// 		if ok {
-// 			ctx.String(http.StatusOK, res)
-// 		} else {
-// 			ctx.String(http.StatusNotFound, res)
-// 		}
-// 		return
-// 	}
-// 	ctx.Status(http.StatusBadRequest)
-// }
+// collects hot posts every 15 minutes and refreshes the subreddits once a day until ctrl+c
+// REDDITOR_CHECKPOINTS_FILE keeps the schedules from starting over on every restart
+func RunScheduledCollection() {
+	config := sdk.NewCollectorConfig(localFileStore)
+	config.Schedules = []sdk.CollectionSchedule{
+		{Name: "hot", Interval: 15 * time.Minute},
+		{Name: "subreddits", Interval: 24 * time.Hour, Policy: &sdk.CollectionPolicy{SubredditsOnly: true}},
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	sdk.NewCollector(config).Run(ctx)
+}
 
-// func redditAuthorizeHandler(ctx *gin.Context) {
-// 	userid := sdk.DEFAULT_USERID
-// 	var params appAuthorizationParams
-// 	if ctx.BindQuery(&params) == nil && params.UserId != "" {
-// 		userid = params.UserId
-// 	}
+// serves the pages to link reddit accounts. the accounts linked here get collected along with the master account
+// REDDITOR_OAUTH_REDIRECT_URI needs to be http://localhost:8080/reddit/callback for this
+// the signed in user comes from the X-Forwarded-User header of an authenticating proxy, which is why it only listens on localhost
+// an app with its own sessions would look the user up in those instead
+func ServeAccountLinking() {
+	collector := sdk.NewCollector(sdk.NewCollectorConfig(localFileStore))
+	mux := http.NewServeMux()
+	sdk.NewOauthHandler(collector, proxyUser).Register(mux, "/reddit")
+	http.ListenAndServe("localhost:8080", mux)
+}
 
-// 	data := []byte(fmt.Sprintf("<html><body><a href=\"%s\">Sign-in with Reddit</a></body></html>", sdk.GetRedditAuthorizationUrl(userid, config.RedditClientConfig)))
-// 	ctx.Data(http.StatusOK, "text/html", data)
-// }
+func proxyUser(r *http.Request) (string, error) {
+	if user := r.Header.Get("X-Forwarded-User"); user != "" {
+		return user, nil
+	}
+	return "", errors.New("not signed in")
+}